@@ -0,0 +1,266 @@
+package dirtree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxLinkHops = 255
+
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether path names a regular file with a zip, tar or
+// gzipped tar suffix.
+func IsArchive(archivePath string) bool {
+	fileInfo, err := os.Stat(archivePath)
+	if err != nil || !fileInfo.Mode().IsRegular() {
+		return false
+	}
+
+	lowerPath := strings.ToLower(archivePath)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(lowerPath, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// openArchive exposes the members of a zip or tar archive as an fs.FS rooted
+// at ".". Only the metadata is kept, so the returned filesystem can be walked
+// but the contents of its files cannot be read.
+func openArchive(archivePath string) (fs.FS, io.Closer, error) {
+	lowerPath := strings.ToLower(archivePath)
+	if strings.HasSuffix(lowerPath, ".zip") {
+		zipReader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zipReader, zipReader, nil
+	}
+
+	archiveFile, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer archiveFile.Close()
+
+	var in io.Reader = archiveFile
+	if strings.HasSuffix(lowerPath, ".gz") || strings.HasSuffix(lowerPath, ".tgz") {
+		gzipReader, err := gzip.NewReader(archiveFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzipReader.Close()
+		in = gzipReader
+	}
+
+	fsys, err := readTarFS(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fsys, io.NopCloser(nil), nil
+}
+
+// Archive writes the tree stored in the zip or tar archive at archivePath
+// to out, as Tree does for a directory.
+func Archive(out io.Writer, archivePath string, opts Options) (stats Stats, err error) {
+	fsys, closer, err := openArchive(archivePath)
+	if err != nil {
+		return
+	}
+	defer closer.Close()
+
+	return renderTree(context.Background(), out, fsys, ".", archivePath, opts)
+}
+
+type tarEntry struct {
+	name     string
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	target   string
+	ino      uint64
+	children []*tarEntry
+}
+
+func (e *tarEntry) Name() string               { return path.Base(e.name) }
+func (e *tarEntry) Size() int64                { return e.size }
+func (e *tarEntry) Mode() fs.FileMode          { return e.mode }
+func (e *tarEntry) ModTime() time.Time         { return e.modTime }
+func (e *tarEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *tarEntry) Sys() any                   { return fileID{ino: e.ino} }
+func (e *tarEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *tarEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+type tarFS struct {
+	entries map[string]*tarEntry
+}
+
+func cleanMemberName(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+func readTarFS(in io.Reader) (*tarFS, error) {
+	fsys := &tarFS{entries: map[string]*tarEntry{
+		".": {name: ".", mode: fs.ModeDir | 0755},
+	}}
+
+	tarReader := tar.NewReader(in)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := cleanMemberName(header.Name)
+		if name == "" {
+			continue
+		}
+		entry := fsys.ensureEntry(name)
+		entry.size = header.Size
+		entry.modTime = header.ModTime
+		entry.mode = header.FileInfo().Mode()
+		if header.Typeflag == tar.TypeSymlink {
+			entry.target = header.Linkname
+		}
+	}
+
+	for _, entry := range fsys.entries {
+		sort.Slice(entry.children, func(i, j int) bool {
+			return entry.children[i].name < entry.children[j].name
+		})
+	}
+	return fsys, nil
+}
+
+func (fsys *tarFS) ensureEntry(name string) *tarEntry {
+	if entry, ok := fsys.entries[name]; ok {
+		return entry
+	}
+
+	parentName := path.Dir(name)
+	parent := fsys.ensureEntry(parentName)
+	if !parent.IsDir() {
+		parent.mode = fs.ModeDir | 0755
+	}
+
+	entry := &tarEntry{name: name, mode: fs.ModeDir | 0755, ino: uint64(len(fsys.entries))}
+	parent.children = append(parent.children, entry)
+	fsys.entries[name] = entry
+	return entry
+}
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// resolve finds the entry for name, following symbolic links in every
+// intermediate component and, if followLast is set, in the final one.
+func (fsys *tarFS) resolve(name string, followLast bool, hops int) (*tarEntry, error) {
+	current := fsys.entries["."]
+	if name == "." {
+		return current, nil
+	}
+
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		entry, ok := fsys.entries[joinPath(current.name, part)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		if entry.target != "" && (i < len(parts)-1 || followLast) {
+			if hops == maxLinkHops {
+				return nil, errTooManyLinks
+			}
+			target := cleanMemberName(path.Join(path.Dir(entry.name), entry.target))
+			if target == "" {
+				target = "."
+			}
+			var err error
+			if entry, err = fsys.resolve(target, true, hops+1); err != nil {
+				return nil, err
+			}
+		}
+		current = entry
+	}
+	return current, nil
+}
+
+func (fsys *tarFS) lookup(op string, name string, followLinks bool) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry, err := fsys.resolve(name, followLinks, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return entry, nil
+}
+
+func (fsys *tarFS) Open(name string) (fs.File, error) {
+	entry, err := fsys.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &tarFile{entry: entry}, nil
+}
+
+func (fsys *tarFS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.lookup("stat", name, true)
+}
+
+func (fsys *tarFS) Lstat(name string) (fs.FileInfo, error) {
+	return fsys.lookup("lstat", name, false)
+}
+
+func (fsys *tarFS) ReadLink(name string) (string, error) {
+	entry, err := fsys.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if entry.target == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return entry.target, nil
+}
+
+func (fsys *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := fsys.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	dirEntries := make([]fs.DirEntry, len(entry.children))
+	for i, child := range entry.children {
+		dirEntries[i] = child
+	}
+	return dirEntries, nil
+}
+
+type tarFile struct {
+	entry *tarEntry
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *tarFile) Close() error               { return nil }
+
+func (f *tarFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.entry.name, Err: errors.New("archive contents are not kept")}
+}
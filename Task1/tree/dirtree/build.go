@@ -0,0 +1,159 @@
+package dirtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SpecEntry is one line of a tree diagram in the format written by
+// dirTree, with the path rebuilt from the indention.
+type SpecEntry struct {
+	Path   string
+	IsDir  bool
+	Size   int64
+	Target string
+}
+
+const sizePattern = `(?:empty|vary|\d+b|\d+\.\d[kKMGTPE])`
+
+var (
+	fileLabelRe = regexp.MustCompile(`^(.*) \((empty|\d+b)\)$`)
+	// unbuildableRe matches what dirTree appends to a name but cannot be
+	// turned back into a file: masked, human readable and diff sizes, the
+	// markers of errors, links, diffs and the file limit, and the elision line.
+	unbuildableRe = regexp.MustCompile(`(^… \(\d+ more entr(?:y|ies)\)$)|` +
+		` (\((?:vary|\d+\.\d[kKMGTPE]|` + sizePattern + ` -> ` + sizePattern + `)\)|` +
+		`\[(?:recursive, not followed|error opening dir|added|removed|changed|\d+ entries, over the file limit)\])$`)
+)
+
+// parseEntryLabel rejects the suffixes written by dirTree that it cannot
+// rebuild, such as masked or human readable sizes and read errors, rather
+// than taking them for part of a name. Any other text in parentheses or
+// brackets is part of the name.
+func parseEntryLabel(label string) (entry SpecEntry, err error) {
+	if err = checkBuildable(label); err != nil {
+		return
+	}
+	if match := fileLabelRe.FindStringSubmatch(label); match != nil {
+		if err = checkBuildable(match[1]); err != nil {
+			return
+		}
+		entry.Path = match[1]
+		if match[2] != "empty" {
+			entry.Size, _ = strconv.ParseInt(strings.TrimSuffix(match[2], "b"), 10, 64)
+		}
+		return
+	}
+	if name, target, ok := strings.Cut(label, " -> "); ok {
+		entry.Path, entry.Target = name, target
+		return
+	}
+	entry.Path, entry.IsDir = label, true
+	return
+}
+
+func checkBuildable(label string) error {
+	if match := unbuildableRe.FindString(label); match != "" {
+		return fmt.Errorf("cannot interpret %q", strings.TrimPrefix(match, " "))
+	}
+	return nil
+}
+
+// ParseText reads a tree diagram drawn with the classic glyphs and
+// returns its entries in order, parents before their children.
+func ParseText(in io.Reader) ([]SpecEntry, error) {
+	var entries []SpecEntry
+	var parents []SpecEntry
+
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		depth := 0
+		for {
+			if rest, ok := strings.CutPrefix(line, classicPrefixes.vertical); ok {
+				line = rest
+			} else if rest, ok := strings.CutPrefix(line, classicPrefixes.blank); ok {
+				line = rest
+			} else {
+				break
+			}
+			depth++
+		}
+		label, ok := strings.CutPrefix(line, classicPrefixes.branch)
+		if !ok {
+			label, ok = strings.CutPrefix(line, classicPrefixes.lastBranch)
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing %q or %q before the name", lineNumber, classicPrefixes.branch, classicPrefixes.lastBranch)
+		}
+		if depth > len(parents) {
+			return nil, fmt.Errorf("line %d: indented deeper than its parent", lineNumber)
+		}
+		parents = parents[:depth]
+		if depth > 0 && !parents[depth-1].IsDir {
+			return nil, fmt.Errorf("line %d: %s is not a directory", lineNumber, parents[depth-1].Path)
+		}
+
+		entry, err := parseEntryLabel(label)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if entry.Path == "" || entry.Path == "." || entry.Path == ".." || strings.Contains(entry.Path, "/") {
+			return nil, fmt.Errorf("line %d: invalid name %q", lineNumber, entry.Path)
+		}
+		if depth > 0 {
+			entry.Path = path.Join(parents[depth-1].Path, entry.Path)
+		}
+		entries = append(entries, entry)
+		parents = append(parents, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Build creates the entries under root: directories, files of the given
+// size filled with zeros, and symbolic links.
+func Build(root string, entries []SpecEntry) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(root, filepath.FromSlash(entry.Path))
+		var err error
+		switch {
+		case entry.IsDir:
+			err = os.Mkdir(entryPath, 0755)
+		case entry.Target != "":
+			err = os.Symlink(entry.Target, entryPath)
+		default:
+			err = createSizedFile(entryPath, entry.Size)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createSizedFile(filePath string, size int64) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,159 @@
+package dirtree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ColorMode selects when entry names are colored from LS_COLORS. The zero
+// value leaves them plain, like ColorNever.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+func (mode *ColorMode) String() string {
+	return string(*mode)
+}
+
+func (mode *ColorMode) Set(value string) error {
+	switch ColorMode(value) {
+	case ColorAuto, ColorAlways, ColorNever:
+		*mode = ColorMode(value)
+		return nil
+	}
+	return fmt.Errorf("unknown color mode %q", value)
+}
+
+// ResolveColorMode turns auto into always or never: colors are used only when
+// out is a terminal and NO_COLOR is unset or empty.
+func ResolveColorMode(mode ColorMode, out io.Writer) ColorMode {
+	if mode != ColorAuto {
+		return mode
+	}
+	if os.Getenv("NO_COLOR") != "" || !isTerminal(out) {
+		return ColorNever
+	}
+	return ColorAlways
+}
+
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	fileInfo, err := file.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// defaultLSColors is the part of the GNU ls color database that applies to
+// tree entries; LS_COLORS is applied on top of it.
+const defaultLSColors = "di=01;34:ln=01;36:pi=40;33:so=01;35:bd=40;33;01:cd=40;33;01:" +
+	"su=37;41:sg=30;43:tw=30;42:ow=34;42:st=37;44:ex=01;32"
+
+type colorSuffix struct {
+	suffix string
+	code   string
+}
+
+// colorizer wraps entry names in the ANSI escape sequences configured by an
+// LS_COLORS specification. A nil colorizer leaves names untouched.
+type colorizer struct {
+	types    map[string]string
+	suffixes []colorSuffix
+}
+
+func newColorizer(opts Options) *colorizer {
+	if opts.Color != ColorAlways {
+		return nil
+	}
+	return parseLSColors(defaultLSColors + ":" + opts.LSColors)
+}
+
+func parseLSColors(spec string) *colorizer {
+	colors := &colorizer{types: make(map[string]string)}
+	for _, field := range strings.Split(spec, ":") {
+		key, code, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if strings.HasPrefix(key, "*") {
+			colors.suffixes = append(colors.suffixes, colorSuffix{suffix: strings.ToLower(key[1:]), code: code})
+			continue
+		}
+		colors.types[key] = code
+	}
+	return colors
+}
+
+func (colors *colorizer) suffixCode(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for i := len(colors.suffixes) - 1; i >= 0; i-- {
+		if strings.HasSuffix(name, colors.suffixes[i].suffix) {
+			return colors.suffixes[i].code, true
+		}
+	}
+	return "", false
+}
+
+func (colors *colorizer) code(entry Entry) string {
+	if entry.LinkTarget != "" && colors.types["ln"] != "target" {
+		return colors.types["ln"]
+	}
+
+	mode := entry.Info.Mode()
+	switch {
+	case mode.IsDir():
+		sticky, otherWritable := mode&os.ModeSticky != 0, mode.Perm()&0002 != 0
+		switch {
+		case sticky && otherWritable:
+			return colors.types["tw"]
+		case otherWritable:
+			return colors.types["ow"]
+		case sticky:
+			return colors.types["st"]
+		}
+		return colors.types["di"]
+	case mode&os.ModeSymlink != 0:
+		if colors.types["ln"] == "target" {
+			return ""
+		}
+		return colors.types["ln"]
+	case mode&os.ModeNamedPipe != 0:
+		return colors.types["pi"]
+	case mode&os.ModeSocket != 0:
+		return colors.types["so"]
+	case mode&os.ModeCharDevice != 0:
+		return colors.types["cd"]
+	case mode&os.ModeDevice != 0:
+		return colors.types["bd"]
+	case mode&os.ModeSetuid != 0:
+		return colors.types["su"]
+	case mode&os.ModeSetgid != 0:
+		return colors.types["sg"]
+	case mode.Perm()&0111 != 0:
+		return colors.types["ex"]
+	}
+	if code, ok := colors.suffixCode(entry.Name); ok {
+		return code
+	}
+	return colors.types["fi"]
+}
+
+// paint returns the name of entry wrapped in its color, if it has one.
+func (colors *colorizer) paint(entry Entry) string {
+	if colors == nil || isElision(entry) {
+		return entry.Name
+	}
+
+	code := colors.code(entry)
+	if code == "" || code == "0" || code == "00" {
+		return entry.Name
+	}
+	return "\x1b[" + code + "m" + entry.Name + "\x1b[0m"
+}
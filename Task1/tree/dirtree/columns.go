@@ -0,0 +1,97 @@
+package dirtree
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+)
+
+// DefaultTimeFormat is the layout of the modification time column when
+// Options.TimeFormat is empty.
+const DefaultTimeFormat = "Jan _2 15:04"
+
+// columnFormatter builds the bracketed metadata prefix printed in front of
+// entry names, resolving user and group ids once per id.
+type columnFormatter struct {
+	opts   Options
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+func newColumnFormatter(opts Options) *columnFormatter {
+	return &columnFormatter{opts: opts, users: make(map[uint32]string), groups: make(map[uint32]string)}
+}
+
+func (c *columnFormatter) enabled() bool {
+	return c.opts.ShowInode || c.opts.ShowPermissions || c.opts.ShowOwner || c.opts.ShowGroup || c.opts.ShowMtime || c.opts.Hash != ""
+}
+
+func (c *columnFormatter) userName(uid uint32) string {
+	if name, ok := c.users[uid]; ok {
+		return name
+	}
+	name := fmt.Sprint(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	c.users[uid] = name
+	return name
+}
+
+func (c *columnFormatter) groupName(gid uint32) string {
+	if name, ok := c.groups[gid]; ok {
+		return name
+	}
+	name := fmt.Sprint(gid)
+	if g, err := user.LookupGroupId(name); err == nil {
+		name = g.Name
+	}
+	c.groups[gid] = name
+	return name
+}
+
+func (c *columnFormatter) format(entry Entry) string {
+	if !c.enabled() || isElision(entry) {
+		return ""
+	}
+
+	var columns []string
+	if c.opts.ShowInode {
+		inode := "?"
+		if id, ok := getFileID(entry.Info); ok {
+			inode = fmt.Sprint(id.ino)
+		}
+		columns = append(columns, inode)
+	}
+	if c.opts.ShowPermissions {
+		columns = append(columns, entry.Info.Mode().String())
+	}
+	if c.opts.ShowOwner || c.opts.ShowGroup {
+		uid, gid, ok := getFileOwner(entry.Info)
+		if c.opts.ShowOwner {
+			owner := "?"
+			if ok {
+				owner = c.userName(uid)
+			}
+			columns = append(columns, owner)
+		}
+		if c.opts.ShowGroup {
+			group := "?"
+			if ok {
+				group = c.groupName(gid)
+			}
+			columns = append(columns, group)
+		}
+	}
+	if c.opts.ShowMtime {
+		layout := c.opts.TimeFormat
+		if layout == "" {
+			layout = DefaultTimeFormat
+		}
+		columns = append(columns, entry.Info.ModTime().Format(layout))
+	}
+	if c.opts.Hash != "" {
+		columns = append(columns, entry.Digest.String())
+	}
+	return "[" + strings.Join(columns, " ") + "] "
+}
@@ -0,0 +1,184 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Change marks how an entry of a Diff differs between the old and the new
+// tree; unchanged entries have the empty Change.
+type Change string
+
+const (
+	Added   Change = "added"
+	Removed Change = "removed"
+	Changed Change = "changed"
+)
+
+// diffInfo describes an entry of the merged tree. It carries the info from
+// the new tree when the entry exists there and from the old tree otherwise.
+type diffInfo struct {
+	os.FileInfo
+	change  Change
+	oldSize int64
+}
+
+func newDiffInfo(newInfo fs.FileInfo, oldInfo fs.FileInfo) fs.FileInfo {
+	switch {
+	case oldInfo == nil:
+		return &diffInfo{FileInfo: newInfo, change: Added}
+	case newInfo == nil:
+		return &diffInfo{FileInfo: oldInfo, change: Removed, oldSize: oldInfo.Size()}
+	case newInfo.Mode().Type() != oldInfo.Mode().Type():
+		return &diffInfo{FileInfo: newInfo, change: Changed, oldSize: oldInfo.Size()}
+	case newInfo.Mode().IsRegular() && newInfo.Size() != oldInfo.Size():
+		return &diffInfo{FileInfo: newInfo, change: Changed, oldSize: oldInfo.Size()}
+	}
+	return &diffInfo{FileInfo: newInfo, oldSize: oldInfo.Size()}
+}
+
+func entryChange(fileInfo os.FileInfo) (Change, int64) {
+	if link, ok := fileInfo.(*symlinkInfo); ok {
+		fileInfo = link.FileInfo
+	}
+	if diff, ok := fileInfo.(*diffInfo); ok {
+		return diff.change, diff.oldSize
+	}
+	return "", 0
+}
+
+// diffFS merges two trees so that the walker can render them as one. Every
+// operation prefers the new tree and falls back to the old one for entries
+// that were removed.
+type diffFS struct {
+	old fs.FS
+	new fs.FS
+}
+
+func isMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+func (fsys *diffFS) Open(name string) (fs.File, error) {
+	file, err := fsys.new.Open(name)
+	if isMissing(err) {
+		return fsys.old.Open(name)
+	}
+	return file, err
+}
+
+func (fsys *diffFS) stat(name string, stat func(fs.FS, string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	newInfo, newErr := stat(fsys.new, name)
+	if newErr != nil && !isMissing(newErr) {
+		return nil, newErr
+	}
+	oldInfo, oldErr := stat(fsys.old, name)
+	if oldErr != nil && !isMissing(oldErr) {
+		return nil, oldErr
+	}
+	if newErr != nil && oldErr != nil {
+		return nil, newErr
+	}
+	return newDiffInfo(newInfo, oldInfo), nil
+}
+
+func (fsys *diffFS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.stat(name, fs.Stat)
+}
+
+func (fsys *diffFS) Lstat(name string) (fs.FileInfo, error) {
+	return fsys.stat(name, fs.Lstat)
+}
+
+func (fsys *diffFS) ReadLink(name string) (string, error) {
+	target, err := fs.ReadLink(fsys.new, name)
+	if isMissing(err) {
+		return fs.ReadLink(fsys.old, name)
+	}
+	return target, err
+}
+
+// readSide lists name in one of the trees, treating a missing directory, or
+// a file in its place, as an empty one.
+func readSide(fsys fs.FS, name string) (map[string]fs.FileInfo, error) {
+	dirEntries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		if fileInfo, statErr := fs.Stat(fsys, name); isMissing(statErr) || statErr == nil && !fileInfo.IsDir() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	infos := make(map[string]fs.FileInfo, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		fileInfo, err := dirEntry.Info()
+		if isMissing(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos[dirEntry.Name()] = fileInfo
+	}
+	return infos, nil
+}
+
+func (fsys *diffFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	newInfos, err := readSide(fsys.new, name)
+	if err != nil {
+		return nil, err
+	}
+	oldInfos, err := readSide(fsys.old, name)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries := make([]fs.DirEntry, 0, len(newInfos)+len(oldInfos))
+	for entryName, newInfo := range newInfos {
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(newDiffInfo(newInfo, oldInfos[entryName])))
+	}
+	for entryName, oldInfo := range oldInfos {
+		if _, ok := newInfos[entryName]; !ok {
+			dirEntries = append(dirEntries, fs.FileInfoToDirEntry(newDiffInfo(nil, oldInfo)))
+		}
+	}
+	sort.Slice(dirEntries, func(i, j int) bool {
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+	return dirEntries, nil
+}
+
+func openTree(path string) (fs.FS, io.Closer, error) {
+	if IsArchive(path) {
+		return openArchive(path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	return os.DirFS(path), io.NopCloser(nil), nil
+}
+
+// Diff renders the merged tree of oldPath and newPath, marking the entries
+// that were added, removed or changed size between the two. Either path may
+// also be an archive.
+func Diff(out io.Writer, oldPath string, newPath string, opts Options) (stats Stats, err error) {
+	oldFS, oldCloser, err := openTree(oldPath)
+	if err != nil {
+		return
+	}
+	defer oldCloser.Close()
+
+	newFS, newCloser, err := openTree(newPath)
+	if err != nil {
+		return
+	}
+	defer newCloser.Close()
+
+	displayRoot := fmt.Sprintf("%s => %s", oldPath, newPath)
+	return renderTree(context.Background(), out, &diffFS{old: oldFS, new: newFS}, ".", displayRoot, opts)
+}
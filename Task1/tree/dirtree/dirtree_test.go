@@ -0,0 +1,1392 @@
+package dirtree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const testDataResult = `├───project
+│	├───file.txt (19b)
+│	└───gopher.png (70372b)
+├───static
+│	├───css
+│	│	└───body.css (28b)
+│	├───html
+│	│	└───index.html (57b)
+│	└───js
+│		└───site.js (10b)
+├───zline
+│	└───empty.txt (empty)
+└───zzfile.txt (empty)
+`
+
+func TestTreeOptions(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755)
+	os.WriteFile(filepath.Join(root, "a", ".hidden"), nil, 0644)
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, MaxDepth: 2, HideHidden: true}, "└───a\n\t└───b\n"},
+		{Options{PrintFiles: true, MaxDepth: 1}, "└───a\n"},
+		{Options{PrintFiles: true, ExcludePattern: "b"}, "└───a\n\t└───.hidden (empty)\n"},
+		{Options{PrintFiles: true, IncludePattern: "*.txt|*.go", MaxDepth: 2}, "└───a\n\t└───b\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := Tree(out, root, c.opts)
+		if err != nil {
+			t.Errorf("test for OK Failed - error: %v", err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestTreeIncludePattern(t *testing.T) {
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata/project", Options{PrintFiles: true, IncludePattern: "*.png"})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "└───gopher.png (70372b)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	ordered := []string{"file1", "file2", "file10", "file10a", "v1.2", "v1.10", "x"}
+	for i := 0; i < len(ordered)-1; i++ {
+		if !naturalLess(ordered[i], ordered[i+1]) || naturalLess(ordered[i+1], ordered[i]) {
+			t.Errorf("test for %q < %q Failed", ordered[i], ordered[i+1])
+		}
+	}
+}
+
+func TestTreeSort(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "dir10"), 0755)
+	os.Mkdir(filepath.Join(root, "dir9"), 0755)
+	os.WriteFile(filepath.Join(root, "a.txt"), make([]byte, 5), 0644)
+	os.WriteFile(filepath.Join(root, "b.txt"), make([]byte, 50), 0644)
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true}, "├───a.txt (5b)\n├───b.txt (50b)\n├───dir10\n└───dir9\n"},
+		{Options{PrintFiles: true, Sort: SortByVersion}, "├───a.txt (5b)\n├───b.txt (50b)\n├───dir9\n└───dir10\n"},
+		{Options{PrintFiles: true, Sort: SortBySize, ExcludePattern: "dir*"}, "├───b.txt (50b)\n└───a.txt (5b)\n"},
+		{Options{PrintFiles: true, DirsFirst: true}, "├───dir10\n├───dir9\n├───a.txt (5b)\n└───b.txt (50b)\n"},
+		{Options{PrintFiles: true, DirsFirst: true, ReverseSort: true, Sort: SortByVersion}, "├───dir10\n├───dir9\n├───b.txt (50b)\n└───a.txt (5b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := Tree(out, root, c.opts)
+		if err != nil {
+			t.Errorf("test for OK Failed - error: %v", err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+type testJSONNode struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Size     int64           `json:"size"`
+	Children []*testJSONNode `json:"children"`
+}
+
+func TestTreeJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata/static", Options{PrintFiles: true, Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+
+	root := new(testJSONNode)
+	if err := json.Unmarshal(out.Bytes(), root); err != nil {
+		t.Fatalf("test for OK Failed - invalid json: %v\n%s", err, out.String())
+	}
+	if root.Name != "../testdata/static" || root.Type != "directory" || len(root.Children) != 3 {
+		t.Fatalf("test for OK Failed - unexpected root %+v", root)
+	}
+	css := root.Children[0]
+	if css.Name != "css" || len(css.Children) != 1 {
+		t.Fatalf("test for OK Failed - unexpected css dir %+v", css)
+	}
+	if body := css.Children[0]; body.Name != "body.css" || body.Type != "file" || body.Size != 28 {
+		t.Errorf("test for OK Failed - unexpected file %+v", body)
+	}
+}
+
+func TestTreeJSONFarMtime(t *testing.T) {
+	future := time.Date(12000, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"later":          {Mode: fs.ModeDir | 0755, ModTime: future},
+		"later/note.txt": {Data: []byte("hi"), ModTime: future},
+	}
+
+	out := new(bytes.Buffer)
+	if _, err := TreeFS(out, fsys, ".", Options{PrintFiles: true, Format: FormatJSON}); err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+	var root struct {
+		Children []struct {
+			Mtime    string
+			Children []struct{ Name, Mtime string }
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+		t.Fatalf("test for OK Failed - invalid json: %v\n%s", err, out.String())
+	}
+	if len(root.Children) != 1 || root.Children[0].Mtime != "12000-01-02T03:04:05Z" || len(root.Children[0].Children) != 1 {
+		t.Errorf("test for OK Failed - unexpected tree\n%s", out.String())
+	}
+}
+
+type testXMLNode struct {
+	XMLName  xml.Name
+	Name     string         `xml:"name,attr"`
+	Size     int64          `xml:"size,attr"`
+	Children []*testXMLNode `xml:",any"`
+}
+
+type testXMLTree struct {
+	Root        testXMLNode `xml:"directory"`
+	Directories int         `xml:"report>directories"`
+	Files       int         `xml:"report>files"`
+}
+
+func TestTreeXML(t *testing.T) {
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata", Options{PrintFiles: true, Format: FormatXML})
+	if err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+
+	tree := new(testXMLTree)
+	if err := xml.Unmarshal(out.Bytes(), tree); err != nil {
+		t.Fatalf("test for OK Failed - invalid xml: %v\n%s", err, out.String())
+	}
+	if tree.Root.Name != "../testdata" || len(tree.Root.Children) != 4 || tree.Directories != 6 || tree.Files != 7 {
+		t.Fatalf("test for OK Failed - unexpected tree %+v", tree)
+	}
+	gopher := tree.Root.Children[0].Children[1]
+	if gopher.XMLName.Local != "file" || gopher.Name != "gopher.png" || gopher.Size != 70372 {
+		t.Errorf("test for OK Failed - unexpected file %+v", gopher)
+	}
+}
+
+func TestTreeRenderers(t *testing.T) {
+	cases := []struct {
+		format   Format
+		expected string
+	}{
+		{FormatASCII, "|-- css\n|   `-- body.css (28b)\n|-- html\n|   `-- index.html (57b)\n`-- js\n    `-- site.js (10b)\n"},
+		{FormatUnicode, "├── css\n│   └── body.css (28b)\n├── html\n│   └── index.html (57b)\n└── js\n    └── site.js (10b)\n"},
+		{FormatIndent, "css\n\tbody.css (28b)\nhtml\n\tindex.html (57b)\njs\n\tsite.js (10b)\n"},
+		{FormatMarkdown, "- css/\n  - body.css (28b)\n- html/\n  - index.html (57b)\n- js/\n  - site.js (10b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := Tree(out, "../testdata/static", Options{PrintFiles: true, Format: c.format})
+		if err != nil {
+			t.Errorf("test for %s Failed - error: %v", c.format, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %s Failed - results not match\nGot:\n%v\nExpected:\n%v", c.format, result, c.expected)
+		}
+	}
+}
+
+func TestTreeIndentation(t *testing.T) {
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{IndentStyle: IndentSpaces, IndentWidth: 1}, "├ css\n│ └ body.css (28b)\n├ html\n│ └ index.html (57b)\n└ js\n  └ site.js (10b)\n"},
+		{Options{IndentStyle: IndentSpaces, IndentWidth: 2}, "├ css\n│ └ body.css (28b)\n├ html\n│ └ index.html (57b)\n└ js\n  └ site.js (10b)\n"},
+		{Options{IndentStyle: IndentSpaces, IndentWidth: 3, Glyphs: ASCIIGlyphs}, "|- css\n|  `- body.css (28b)\n|- html\n|  `- index.html (57b)\n`- js\n   `- site.js (10b)\n"},
+		{Options{Format: FormatUnicode, IndentStyle: IndentTabs}, "├───css\n│\t└───body.css (28b)\n├───html\n│\t└───index.html (57b)\n└───js\n\t└───site.js (10b)\n"},
+		{Options{Format: FormatIndent, IndentStyle: IndentSpaces, IndentWidth: 2}, "css\n  body.css (28b)\nhtml\n  index.html (57b)\njs\n  site.js (10b)\n"},
+		{Options{Glyphs: Glyphs{Tee: "+", Corner: "+", Horizontal: "=", Vertical: "!"}}, "+===css\n!\t+===body.css (28b)\n+===html\n!\t+===index.html (57b)\n+===js\n\t+===site.js (10b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		c.opts.PrintFiles = true
+		_, err := Tree(out, "../testdata/static", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestGlyphsSet(t *testing.T) {
+	var glyphs Glyphs
+	if err := glyphs.Set("+\\-|"); err != nil || glyphs != (Glyphs{Tee: "+", Corner: "\\", Horizontal: "-", Vertical: "|"}) {
+		t.Errorf("test for custom glyphs Failed - got %+v, error %v", glyphs, err)
+	}
+	if err := glyphs.Set("ascii"); err != nil || glyphs != ASCIIGlyphs || glyphs.String() != "ascii" {
+		t.Errorf("test for ascii glyphs Failed - got %+v, error %v", glyphs, err)
+	}
+	if err := glyphs.Set("+-"); err == nil {
+		t.Errorf("test for short glyphs Failed - expected error")
+	}
+}
+
+func TestTreeHTML(t *testing.T) {
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata/zline", Options{PrintFiles: true, Format: FormatHTML})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "<ul>\n  <li class=\"file\">empty.txt (empty)</li>\n</ul>\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+type recordingRenderer struct {
+	events []string
+	stats  Stats
+}
+
+func (r *recordingRenderer) BeginDir(entry Entry) {
+	r.events = append(r.events, fmt.Sprintf("begin %s %d", entry.Name, entry.Depth))
+}
+
+func (r *recordingRenderer) File(entry Entry) {
+	r.events = append(r.events, fmt.Sprintf("file %s %d", entry.Name, entry.Depth))
+}
+
+func (r *recordingRenderer) EndDir(entry Entry) {
+	r.events = append(r.events, fmt.Sprintf("end %s %d", entry.Name, entry.Depth))
+}
+
+func (r *recordingRenderer) Summary(stats Stats) {
+	r.stats = stats
+}
+
+func TestTreeCustomRenderer(t *testing.T) {
+	r := new(recordingRenderer)
+	_, err := Tree(nil, "../testdata/project", Options{PrintFiles: true, Renderer: r})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "begin ../testdata/project 0,file file.txt 1,file gopher.png 1,end ../testdata/project 0"
+	if result := strings.Join(r.events, ","); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if r.stats != (Stats{Dirs: 0, Files: 2, Bytes: 70391}) {
+		t.Errorf("test for OK Failed - unexpected stats %+v", r.stats)
+	}
+}
+
+func TestTreeSymlinks(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "dir"), 0755)
+	os.WriteFile(filepath.Join(root, "dir", "file.txt"), make([]byte, 3), 0644)
+	os.Symlink("..", filepath.Join(root, "dir", "loop"))
+	os.Symlink("dir", filepath.Join(root, "link"))
+	os.Symlink("missing", filepath.Join(root, "dangling"))
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true}, "├───dangling -> missing\n├───dir\n│\t├───file.txt (3b)\n│\t└───loop -> ..\n└───link -> dir\n"},
+		{Options{PrintFiles: false, FollowLinks: true}, "├───dir\n│\t└───loop -> .. [recursive, not followed]\n└───link -> dir\n\t└───loop -> .. [recursive, not followed]\n"},
+		{Options{PrintFiles: true, FollowLinks: true}, "├───dangling -> missing\n├───dir\n│\t├───file.txt (3b)\n│\t└───loop -> .. [recursive, not followed]\n└───link -> dir\n\t├───file.txt (3b)\n\t└───loop -> .. [recursive, not followed]\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := Tree(out, root, c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestTreeReport(t *testing.T) {
+	out := new(bytes.Buffer)
+	stats, err := Tree(out, "../testdata", Options{PrintFiles: true, Report: true, ExcludePattern: "zline"})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	if stats != (Stats{Dirs: 5, Files: 6, Bytes: 70486}) {
+		t.Errorf("test for OK Failed - unexpected stats %+v", stats)
+	}
+	expectedFooter := "\n5 directories, 6 files, 70486 bytes total\n"
+	if result := out.String(); !strings.HasSuffix(result, expectedFooter) {
+		t.Errorf("test for OK Failed - missing footer\nGot:\n%v\nExpected suffix:\n%v", result, expectedFooter)
+	}
+
+	out.Reset()
+	stats, err = Tree(out, "../testdata/zline", Options{PrintFiles: true, Report: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "└───empty.txt (empty)\n\n0 directories, 1 file, 0 bytes total\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestHumanizeSize(t *testing.T) {
+	cases := []struct {
+		size     int64
+		si       bool
+		expected string
+	}{
+		{19, false, "19b"},
+		{70372, false, "68.7K"},
+		{70372, true, "70.4k"},
+		{1258291, false, "1.2M"},
+		{5 << 30, false, "5.0G"},
+	}
+	for _, c := range cases {
+		if result := humanizeSize(c.size, c.si); result != c.expected {
+			t.Errorf("test for %d (si %v) Failed - got %q, expected %q", c.size, c.si, result, c.expected)
+		}
+	}
+}
+
+func TestTreeDiskUsage(t *testing.T) {
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata", Options{DiskUsage: true, HumanSizes: true, MaxDepth: 1})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "├───project (68.7K)\n├───static (95b)\n└───zline (empty)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	out.Reset()
+	_, err = Tree(out, "../testdata/static", Options{PrintFiles: true, DiskUsage: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected = "├───css (28b)\n│\t└───body.css (28b)\n├───html (57b)\n│\t└───index.html (57b)\n└───js (10b)\n\t└───site.js (10b)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestTreeSizeMask(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "cmd", "tool"), 0755)
+	os.WriteFile(filepath.Join(root, "main.go"), make([]byte, 10), 0644)
+	os.WriteFile(filepath.Join(root, "cmd", "tool", "main.go"), make([]byte, 20), 0644)
+
+	cases := []struct {
+		maskPattern string
+		expected    string
+	}{
+		{"", "├───cmd\n│\t└───tool\n│\t\t└───main.go (20b)\n└───main.go (10b)\n"},
+		{"main.go", "├───cmd\n│\t└───tool\n│\t\t└───main.go (vary)\n└───main.go (vary)\n"},
+		{"cmd/*/*.go", "├───cmd\n│\t└───tool\n│\t\t└───main.go (vary)\n└───main.go (10b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := Tree(out, root, Options{PrintFiles: true, MaskPattern: c.maskPattern})
+		if err != nil {
+			t.Errorf("test for %q Failed - error: %v", c.maskPattern, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %q Failed - results not match\nGot:\n%v\nExpected:\n%v", c.maskPattern, result, c.expected)
+		}
+	}
+}
+
+type countingWriter struct {
+	writes int
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func TestTreeStreamsOutput(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 500; i++ {
+		os.WriteFile(filepath.Join(root, fmt.Sprintf("file%03d.txt", i)), nil, 0644)
+	}
+
+	out := new(countingWriter)
+	_, err := Tree(out, root, Options{PrintFiles: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	if out.writes < 2 {
+		t.Errorf("test for OK Failed - expected output to be streamed in several writes, got %d", out.writes)
+	}
+
+	failing := &countingWriter{err: io.ErrClosedPipe}
+	_, err = Tree(failing, root, Options{PrintFiles: true})
+	if err != io.ErrClosedPipe {
+		t.Errorf("test for write error Failed - got %v", err)
+	}
+}
+
+func TestTreeKeepGoing(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		os.MkdirAll(filepath.Join(root, name, "inner"), 0755)
+	}
+	os.Chmod(filepath.Join(root, "a"), 0)
+	os.Chmod(filepath.Join(root, "c"), 0)
+	defer os.Chmod(filepath.Join(root, "a"), 0755)
+	defer os.Chmod(filepath.Join(root, "c"), 0755)
+
+	out := new(bytes.Buffer)
+	_, err := Tree(out, root, Options{})
+	if err == nil || out.Len() != 0 {
+		t.Errorf("test for strict mode Failed - got error %v and output %q", err, out.String())
+	}
+
+	out.Reset()
+	_, err = Tree(out, root, Options{KeepGoing: true})
+	expected := "├───a [error opening dir]\n├───b\n│\t└───inner\n└───c [error opening dir]\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for keep going Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(root, "a")) || !strings.Contains(err.Error(), filepath.Join(root, "c")) {
+		t.Errorf("test for keep going Failed - expected both paths in error, got %v", err)
+	}
+}
+
+func TestTreeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/readme.md":    {Data: []byte("hello")},
+		"docs/img/logo.png": {Data: make([]byte, 2048)},
+		"main.go":           {Data: []byte("package main")},
+		"link":              {Data: []byte("docs"), Mode: fs.ModeSymlink},
+	}
+
+	cases := []struct {
+		root     string
+		opts     Options
+		expected string
+	}{
+		{".", Options{PrintFiles: true}, "├───docs\n│\t├───img\n│\t│\t└───logo.png (2048b)\n│\t└───readme.md (5b)\n├───link -> docs\n└───main.go (12b)\n"},
+		{".", Options{FollowLinks: true}, "├───docs\n│\t└───img\n└───link -> docs\n\t└───img\n"},
+		{"docs", Options{PrintFiles: true, MaskPattern: "img/*"}, "├───img\n│\t└───logo.png (vary)\n└───readme.md (5b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := TreeFS(out, fsys, c.root, c.opts)
+		if err != nil {
+			t.Errorf("test for %s %+v Failed - error: %v", c.root, c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %s %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.root, c.opts, result, c.expected)
+		}
+	}
+
+	_, err := TreeFS(io.Discard, fsys, "missing", Options{})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("test for missing root Failed - got %v", err)
+	}
+}
+
+type brokenDirFS struct {
+	fstest.MapFS
+	broken map[string]bool
+}
+
+func (fsys brokenDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if fsys.broken[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.ReadDir(name)
+}
+
+func TestTreeKeepGoingFS(t *testing.T) {
+	fsys := brokenDirFS{
+		MapFS: fstest.MapFS{
+			"a/file.txt":   {},
+			"b/inner/x.go": {},
+			"c/file.txt":   {},
+		},
+		broken: map[string]bool{"a": true, "b/inner": true},
+	}
+
+	out := new(bytes.Buffer)
+	_, err := TreeFS(out, fsys, ".", Options{KeepGoing: true})
+	expected := "├───a [error opening dir]\n├───b\n│\t└───inner [error opening dir]\n└───c\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for keep going Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if err == nil || !strings.Contains(err.Error(), "open a:") || !strings.Contains(err.Error(), "open b/inner:") {
+		t.Errorf("test for keep going Failed - expected both paths in error, got %v", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("test for keep going Failed - expected wrapped permission error, got %v", err)
+	}
+}
+
+func writeTestArchives(t *testing.T, dir string) (zipPath string, tarPath string) {
+	members := []struct {
+		name string
+		size int
+	}{
+		{"bin/tool", 1500},
+		{"share/doc/README", 12},
+		{"VERSION", 0},
+	}
+
+	zipPath = filepath.Join(dir, "release.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	for _, member := range members {
+		w, _ := zipWriter.Create(member.name)
+		w.Write(make([]byte, member.size))
+	}
+	zipWriter.Close()
+	zipFile.Close()
+
+	tarPath = filepath.Join(dir, "release.tar.gz")
+	tarFile, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipWriter := gzip.NewWriter(tarFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, member := range members {
+		tarWriter.WriteHeader(&tar.Header{Name: "./" + member.name, Mode: 0644, Size: int64(member.size)})
+		tarWriter.Write(make([]byte, member.size))
+	}
+	tarWriter.WriteHeader(&tar.Header{Name: "./current", Typeflag: tar.TypeSymlink, Linkname: "share", Mode: 0777})
+	tarWriter.Close()
+	gzipWriter.Close()
+	tarFile.Close()
+	return
+}
+
+func TestTreeArchive(t *testing.T) {
+	zipPath, tarPath := writeTestArchives(t, t.TempDir())
+
+	cases := []struct {
+		path     string
+		opts     Options
+		expected string
+	}{
+		{zipPath, Options{PrintFiles: true}, "├───VERSION (empty)\n├───bin\n│\t└───tool (1500b)\n└───share\n\t└───doc\n\t\t└───README (12b)\n"},
+		{tarPath, Options{PrintFiles: true}, "├───VERSION (empty)\n├───bin\n│\t└───tool (1500b)\n├───current -> share\n└───share\n\t└───doc\n\t\t└───README (12b)\n"},
+		{tarPath, Options{FollowLinks: true, DiskUsage: true}, "├───bin (1500b)\n├───current -> share (12b)\n│\t└───doc (12b)\n└───share (12b)\n\t└───doc (12b)\n"},
+	}
+	for _, c := range cases {
+		if !IsArchive(c.path) {
+			t.Errorf("test for %s Failed - not detected as an archive", c.path)
+		}
+		out := new(bytes.Buffer)
+		_, err := Archive(out, c.path, c.opts)
+		if err != nil {
+			t.Errorf("test for %s Failed - error: %v", c.path, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %s %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.path, c.opts, result, c.expected)
+		}
+	}
+
+	if IsArchive("../testdata") {
+		t.Errorf("test for directory Failed - detected as an archive")
+	}
+}
+
+func TestTreeParallel(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 6; j++ {
+			dir := filepath.Join(root, fmt.Sprintf("dir%d", i), fmt.Sprintf("sub%d", j))
+			os.MkdirAll(filepath.Join(dir, "leaf"), 0755)
+			os.WriteFile(filepath.Join(dir, "file.txt"), make([]byte, i*10+j), 0644)
+		}
+	}
+
+	optionSets := []Options{
+		{PrintFiles: true},
+		{PrintFiles: false},
+		{PrintFiles: true, MaxDepth: 2},
+		{PrintFiles: true, DiskUsage: true, MaxDepth: 1},
+		{PrintFiles: true, Format: FormatJSON, Sort: SortBySize, ReverseSort: true},
+	}
+	for _, opts := range optionSets {
+		sequential := new(bytes.Buffer)
+		sequentialStats, err := Tree(sequential, root, opts)
+		if err != nil {
+			t.Fatalf("test for %+v Failed - error: %v", opts, err)
+		}
+
+		opts.Workers = 8
+		parallel := new(bytes.Buffer)
+		parallelStats, err := Tree(parallel, root, opts)
+		if err != nil {
+			t.Fatalf("test for %+v Failed - error: %v", opts, err)
+		}
+		if parallel.String() != sequential.String() || parallelStats != sequentialStats {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", opts, parallel.String(), sequential.String())
+		}
+	}
+
+	out := new(bytes.Buffer)
+	_, err := Tree(out, "../testdata", Options{PrintFiles: true, Workers: 4})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	if result := out.String(); result != testDataResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDataResult)
+	}
+}
+
+func TestTreeGitIgnore(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":      {Data: []byte("# build outputs\nbuild/\n*.log\n!keep.log\n/vendor\ndocs/**/*.tmp\n")},
+		".git/HEAD":       {},
+		"app.log":         {},
+		"keep.log":        {},
+		"build/out.bin":   {},
+		"vendor/x.go":     {},
+		"docs/a/b/c.tmp":  {},
+		"docs/a/note.md":  {},
+		"src/.gitignore":  {Data: []byte("*.go\n!main.go\n")},
+		"src/build":       {},
+		"src/main.go":     {},
+		"src/util.go":     {},
+		"src/vendor/y.go": {},
+	}
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, GitIgnore: true, HideHidden: true}, "├───docs\n│\t└───a\n│\t\t├───b\n│\t\t└───note.md (empty)\n├───keep.log (empty)\n└───src\n\t├───build (empty)\n\t├───main.go (empty)\n\t└───vendor\n"},
+		{Options{PrintFiles: true, GitIgnore: true, HideGitDir: true, MaxDepth: 1}, "├───.gitignore (61b)\n├───docs\n├───keep.log (empty)\n└───src\n"},
+		{Options{MaxDepth: 1}, "├───.git\n├───build\n├───docs\n├───src\n└───vendor\n"},
+	}
+	for _, c := range cases {
+		for _, workers := range []int{1, 4} {
+			c.opts.Workers = workers
+			out := new(bytes.Buffer)
+			_, err := TreeFS(out, fsys, ".", c.opts)
+			if err != nil {
+				t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+			}
+			result := out.String()
+			if result != c.expected {
+				t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+			}
+		}
+	}
+}
+
+func TestGitIgnoreRules(t *testing.T) {
+	ignore := &gitIgnore{rules: parseGitIgnore("", []byte("a/**/z\n**/cache\nlogs/**\n\\!bang\n[abc].txt\n"))}
+	cases := []struct {
+		relPath  string
+		isDir    bool
+		expected bool
+	}{
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"b/a/z", false, false},
+		{"deep/down/cache", true, true},
+		{"logs/today.txt", false, true},
+		{"logs", true, false},
+		{"!bang", false, true},
+		{"b.txt", false, true},
+		{"d.txt", false, false},
+	}
+	for _, c := range cases {
+		if result := ignore.isIgnored(c.relPath, c.isDir); result != c.expected {
+			t.Errorf("test for %s Failed - got %v, expected %v", c.relPath, result, c.expected)
+		}
+	}
+}
+
+func TestTreeColumns(t *testing.T) {
+	mtime := time.Date(2017, time.October, 31, 20, 41, 27, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"bin":       {Mode: fs.ModeDir | 0755, ModTime: mtime},
+		"bin/tool":  {Data: []byte("#!/bin/sh\n"), Mode: 0755, ModTime: mtime},
+		"notes.txt": {Data: []byte("hi"), Mode: 0600, ModTime: mtime},
+	}
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, ShowPermissions: true}, "├───[drwxr-xr-x] bin\n│\t└───[-rwxr-xr-x] tool (10b)\n└───[-rw-------] notes.txt (2b)\n"},
+		{Options{PrintFiles: true, ShowMtime: true, TimeFormat: "2006-01-02"}, "├───[2017-10-31] bin\n│\t└───[2017-10-31] tool (10b)\n└───[2017-10-31] notes.txt (2b)\n"},
+		{Options{ShowMtime: true, ShowOwner: true, ShowGroup: true}, "└───[? ? Oct 31 20:41] bin\n"},
+		{Options{PrintFiles: true, ShowPermissions: true, Format: FormatMarkdown, MaxDepth: 1}, "- [drwxr-xr-x] bin/\n- [-rw-------] notes.txt (2b)\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := TreeFS(out, fsys, ".", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestTreeOwnerColumn(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skip("current user is unknown")
+	}
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "file.txt"), nil, 0644)
+
+	out := new(bytes.Buffer)
+	_, err = Tree(out, root, Options{PrintFiles: true, ShowOwner: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := "└───[" + current.Username + "] file.txt (empty)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestTreeColors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bin/tool":   {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		"main.go":    {Data: []byte("package main\n"), Mode: 0644},
+		"notes.txt":  {Data: []byte("hi"), Mode: 0644},
+		"latest":     {Data: []byte("main.go"), Mode: fs.ModeSymlink | 0777},
+		"public/tmp": {Mode: fs.ModeDir | fs.ModeSticky | 0777},
+	}
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, Color: ColorNever}, "├───bin\n│\t└───tool (10b)\n├───latest -> main.go\n├───main.go (13b)\n├───notes.txt (2b)\n└───public\n\t└───tmp\n"},
+		{Options{PrintFiles: true, Color: ColorAlways}, "├───\x1b[01;34mbin\x1b[0m\n│\t└───\x1b[01;32mtool\x1b[0m (10b)\n├───\x1b[01;36mlatest\x1b[0m -> main.go\n├───main.go (13b)\n├───notes.txt (2b)\n└───\x1b[01;34mpublic\x1b[0m\n\t└───\x1b[30;42mtmp\x1b[0m\n"},
+		{Options{PrintFiles: true, Color: ColorAlways, LSColors: "di=00:ln=target:*.go=33:*.TXT=35"}, "├───bin\n│\t└───\x1b[01;32mtool\x1b[0m (10b)\n├───latest -> main.go\n├───\x1b[33mmain.go\x1b[0m (13b)\n├───\x1b[35mnotes.txt\x1b[0m (2b)\n└───public\n\t└───\x1b[30;42mtmp\x1b[0m\n"},
+		{Options{PrintFiles: true, Color: ColorAlways, MaskPattern: "tool|*.go"}, "├───\x1b[01;34mbin\x1b[0m\n│\t└───\x1b[01;32mtool\x1b[0m (vary)\n├───\x1b[01;36mlatest\x1b[0m -> main.go\n├───main.go (vary)\n├───notes.txt (2b)\n└───\x1b[01;34mpublic\x1b[0m\n\t└───\x1b[30;42mtmp\x1b[0m\n"},
+		{Options{PrintFiles: true, Color: ColorAlways, Format: FormatMarkdown, MaxDepth: 1}, "- bin/\n- latest -> main.go\n- main.go (13b)\n- notes.txt (2b)\n- public/\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := TreeFS(out, fsys, ".", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%q\nExpected:\n%q", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestResolveColorMode(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if mode := ResolveColorMode(ColorAuto, new(bytes.Buffer)); mode != ColorNever {
+		t.Errorf("test for buffer Failed - got %q", mode)
+	}
+	if mode := ResolveColorMode(ColorAlways, new(bytes.Buffer)); mode != ColorAlways {
+		t.Errorf("test for always Failed - got %q", mode)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if mode := ResolveColorMode(ColorAuto, os.Stdout); mode != ColorNever {
+		t.Errorf("test for NO_COLOR Failed - got %q", mode)
+	}
+}
+
+func TestTreeDiff(t *testing.T) {
+	oldFS := fstest.MapFS{
+		"app.bin":        {Data: []byte("v1")},
+		"lib/libfoo.so":  {Data: []byte("foo")},
+		"lib/libold.so":  {Data: []byte("old")},
+		"cache/index":    {Data: []byte("1234")},
+		"config":         {Data: []byte("a=1")},
+		"docs/README.md": {Data: []byte("readme")},
+	}
+	newFS := fstest.MapFS{
+		"app.bin":        {Data: []byte("v2.0")},
+		"lib/libfoo.so":  {Data: []byte("foo")},
+		"lib/libnew.so":  {Data: []byte("new")},
+		"config/main":    {Data: []byte("a=1")},
+		"docs/README.md": {Data: []byte("readme")},
+	}
+	fsys := &diffFS{old: oldFS, new: newFS}
+
+	out := new(bytes.Buffer)
+	stats, err := TreeFS(out, fsys, ".", Options{PrintFiles: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error: %v", err)
+	}
+	expected := `├───app.bin [changed] (2b -> 4b)
+├───cache [removed]
+│	└───index [removed] (4b)
+├───config [changed]
+│	└───main [added] (3b)
+├───docs
+│	└───README.md (6b)
+└───lib
+	├───libfoo.so (3b)
+	├───libnew.so [added] (3b)
+	└───libold.so [removed] (3b)
+`
+	if result := out.String(); result != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if stats != (Stats{Dirs: 4, Files: 7, Bytes: 26}) {
+		t.Errorf("test for stats Failed - got %+v", stats)
+	}
+
+	out.Reset()
+	_, err = TreeFS(out, fsys, ".", Options{PrintFiles: true, Format: FormatJSON, MaxDepth: 1, IncludePattern: "app.bin"})
+	if err != nil {
+		t.Errorf("test for JSON Failed - error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"name":"app.bin","type":"file","change":"changed","size":4`) {
+		t.Errorf("test for JSON Failed - got:\n%v", out.String())
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/hello.txt":   {Data: []byte("hello")},
+		"a/sub/empty":   {},
+		"b/hello.txt":   {Data: []byte("hello")},
+		"b/sub/empty":   {},
+		"c/hello.txt":   {Data: []byte("hellO")},
+		"c/sub/empty":   {},
+		"top/hello.txt": {Data: []byte("hello")},
+	}
+
+	out := new(bytes.Buffer)
+	_, err := TreeFS(out, fsys, ".", Options{PrintFiles: true, Hash: HashCRC32, MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[1], "[3610a686] hello.txt (5b)") || !strings.Contains(lines[2], "] sub") {
+		t.Errorf("test for file digests Failed - got:\n%v", out.String())
+	}
+	digestOf := func(line string) string {
+		return line[strings.Index(line, "[")+1 : strings.Index(line, "]")]
+	}
+	if digestOf(lines[0]) != digestOf(lines[3]) {
+		t.Errorf("test for equal subtrees Failed - got %q and %q", lines[0], lines[3])
+	}
+	if digestOf(lines[0]) == digestOf(lines[6]) || digestOf(lines[2]) != digestOf(lines[8]) {
+		t.Errorf("test for changed subtree Failed - got:\n%v", out.String())
+	}
+	if digestOf(lines[0]) == digestOf(lines[9]) {
+		t.Errorf("test for different names Failed - got:\n%v", out.String())
+	}
+
+	for _, format := range []Format{FormatText, FormatMarkdown, FormatHTML} {
+		out.Reset()
+		_, err = TreeFS(out, fsys, "a", Options{PrintFiles: true, Hash: HashCRC32, Format: format})
+		if err != nil {
+			t.Errorf("test for %s root digest Failed - error: %v", format, err)
+		}
+		if !strings.Contains(out.String(), digestOf(lines[0])+"  a") {
+			t.Errorf("test for %s root digest Failed - expected %s, got:\n%v", format, digestOf(lines[0]), out.String())
+		}
+	}
+
+	out.Reset()
+	_, err = TreeFS(out, fsys, "top", Options{PrintFiles: true, Hash: HashSHA256, Format: FormatJSON})
+	if err != nil {
+		t.Errorf("test for JSON Failed - error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"name":"hello.txt","type":"file","digest":"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"`) {
+		t.Errorf("test for JSON Failed - got:\n%v", out.String())
+	}
+}
+
+func TestTreeHashArchive(t *testing.T) {
+	zipPath, tarPath := writeTestArchives(t, t.TempDir())
+
+	out := new(bytes.Buffer)
+	_, err := Archive(out, zipPath, Options{PrintFiles: true, Hash: HashCRC32})
+	if err != nil {
+		t.Errorf("test for zip Failed - error: %v", err)
+	}
+	if strings.Contains(out.String(), "?") {
+		t.Errorf("test for zip Failed - got:\n%v", out.String())
+	}
+
+	out.Reset()
+	_, err = Archive(out, tarPath, Options{PrintFiles: true, Hash: HashCRC32})
+	if err == nil {
+		t.Errorf("test for tar Failed - expected an error, the contents are not kept")
+	}
+	if !strings.Contains(out.String(), "[?]") {
+		t.Errorf("test for tar Failed - got:\n%v", out.String())
+	}
+}
+
+func TestTreePrune(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/guide.md":          {Data: []byte("# Guide")},
+		"docs/images/logo.png":   {Data: make([]byte, 2048)},
+		"src/main.go":            {Data: []byte("package main")},
+		"src/internal/util.go":   {Data: []byte("package util")},
+		"src/testdata/input.txt": {Data: []byte("input")},
+		"vendor/empty":           {Mode: fs.ModeDir | 0755},
+		"zz/deep/deeper/x.go":    {Data: []byte("package x")},
+	}
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, Prune: true}, "├───docs\n│\t├───guide.md (7b)\n│\t└───images\n│\t\t└───logo.png (2048b)\n├───src\n│\t├───internal\n│\t│\t└───util.go (12b)\n│\t├───main.go (12b)\n│\t└───testdata\n│\t\t└───input.txt (5b)\n└───zz\n\t└───deep\n\t\t└───deeper\n\t\t\t└───x.go (9b)\n"},
+		{Options{PrintFiles: true, Prune: true, Extensions: "go,.MD"}, "├───docs\n│\t└───guide.md (7b)\n├───src\n│\t├───internal\n│\t│\t└───util.go (12b)\n│\t└───main.go (12b)\n└───zz\n\t└───deep\n\t\t└───deeper\n\t\t\t└───x.go (9b)\n"},
+		{Options{PrintFiles: true, Prune: true, Extensions: "go", MaxDepth: 2}, "└───src\n\t└───main.go (12b)\n"},
+		{Options{Prune: true, IncludePattern: "*.png"}, "└───docs\n\t└───images\n"},
+		{Options{Prune: true, Extensions: "md,txt"}, "├───docs\n└───src\n\t└───testdata\n"},
+		{Options{PrintFiles: true, Prune: true, MinSize: 1024}, "└───docs\n\t└───images\n\t\t└───logo.png (2048b)\n"},
+		{Options{PrintFiles: true, MaxSize: 8, MaxDepth: 2}, "├───docs\n│\t├───guide.md (7b)\n│\t└───images\n├───src\n│\t├───internal\n│\t└───testdata\n├───vendor\n│\t└───empty\n└───zz\n\t└───deep\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		_, err := TreeFS(out, fsys, ".", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+}
+
+func TestTreeFileLimit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"big/a.txt":   {Data: []byte("a")},
+		"big/b.txt":   {Data: []byte("b")},
+		"big/c.txt":   {Data: []byte("c")},
+		"big/d":       {Mode: fs.ModeDir | 0755},
+		"small/x.txt": {Data: []byte("x")},
+		"z.txt":       {Data: []byte("z")},
+	}
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{PrintFiles: true, FileLimit: 2}, "├───big\n│\t├───a.txt (1b)\n│\t├───b.txt (1b)\n│\t└───… (2 more entries)\n├───small\n│\t└───x.txt (1b)\n└───… (1 more entry)\n"},
+		{Options{PrintFiles: true, FileLimit: 3}, "├───big\n│\t├───a.txt (1b)\n│\t├───b.txt (1b)\n│\t├───c.txt (1b)\n│\t└───… (1 more entry)\n├───small\n│\t└───x.txt (1b)\n└───z.txt (1b)\n"},
+		{Options{FileLimit: 1}, "├───big\n│\t└───d\n└───… (1 more entry)\n"},
+		{Options{PrintFiles: true, FileLimit: 3, SkipLargeDirs: true}, "├───big [4 entries, over the file limit]\n├───small\n│\t└───x.txt (1b)\n└───z.txt (1b)\n"},
+		{Options{PrintFiles: true, FileLimit: 1, Format: FormatJSON, MaxDepth: 1}, "{\"name\":\".\",\"type\":\"directory\",\"size\":0,\"mode\":\"dr-xr-xr-x\",\"mtime\":\"0001-01-01T00:00:00Z\",\"children\":[\n  {\"name\":\"big\",\"type\":\"directory\",\"size\":0,\"mode\":\"dr-xr-xr-x\",\"mtime\":\"0001-01-01T00:00:00Z\",\"children\":[\n  ]},\n  {\"name\":\"…\",\"type\":\"elision\",\"omitted\":2}\n]}\n"},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		stats, err := TreeFS(out, fsys, ".", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		result := out.String()
+		if result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+		if c.opts.SkipLargeDirs && stats.Files != 2 {
+			t.Errorf("test for %+v Failed - unexpected stats %+v", c.opts, stats)
+		}
+	}
+
+	nested := fstest.MapFS{
+		"early/a.txt":    {Data: []byte("a")},
+		"early/b.txt":    {Data: []byte("b")},
+		"early/c.txt":    {Data: []byte("c")},
+		"early/y/f.txt":  {Data: make([]byte, 100)},
+		"early/z/f.txt":  {Data: make([]byte, 1000)},
+		"late/a/f.txt":   {Data: make([]byte, 10)},
+		"late/b/f.txt":   {Data: make([]byte, 10)},
+		"late/x.txt":     {Data: []byte("x")},
+		"late/y.txt":     {Data: []byte("y")},
+		"late/zzzzz.txt": {Data: []byte("z")},
+	}
+	nestedCases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{FileLimit: 1, SkipLargeDirs: true}, "├───early [2 entries, over the file limit]\n└───late [2 entries, over the file limit]\n"},
+		{Options{FileLimit: 1, DiskUsage: true}, "├───early (1103b)\n│\t├───y (100b)\n│\t└───… (1 more entry)\n└───… (1 more entry)\n"},
+		{Options{PrintFiles: true, FileLimit: 4, SkipLargeDirs: true, DiskUsage: true}, "├───early [5 entries, over the file limit] (1103b)\n└───late [5 entries, over the file limit] (23b)\n"},
+	}
+	for _, c := range nestedCases {
+		out := new(bytes.Buffer)
+		_, err := TreeFS(out, nested, ".", c.opts)
+		if err != nil {
+			t.Errorf("test for %+v Failed - error: %v", c.opts, err)
+		}
+		if result := out.String(); result != c.expected {
+			t.Errorf("test for %+v Failed - results not match\nGot:\n%v\nExpected:\n%v", c.opts, result, c.expected)
+		}
+	}
+
+	var rootDigests []string
+	for _, opts := range []Options{{MaxDepth: 1, Hash: HashCRC32}, {MaxDepth: 1, Hash: HashCRC32, FileLimit: 1}} {
+		r := &digestRenderer{}
+		opts.Renderer = r
+		if _, err := TreeFS(nil, nested, ".", opts); err != nil {
+			t.Errorf("test for %+v Failed - error: %v", opts, err)
+		}
+		rootDigests = append(rootDigests, r.root)
+	}
+	if rootDigests[0] != rootDigests[1] {
+		t.Errorf("test for digest Failed - root digest changed with the file limit: %v", rootDigests)
+	}
+}
+
+type digestRenderer struct {
+	recordingRenderer
+	root string
+}
+
+func (r *digestRenderer) EndDir(entry Entry) {
+	if entry.Depth == 0 {
+		r.root = entry.Digest.String()
+	}
+}
+
+func TestTreeMaxEntries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/one.txt": {Data: []byte("1")},
+		"a/two.txt": {Data: []byte("22")},
+		"b/three":   {Data: []byte("333")},
+	}
+
+	out := new(bytes.Buffer)
+	stats, err := TreeFS(out, fsys, ".", Options{PrintFiles: true, MaxEntries: 3, Format: FormatJSON})
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("test for budget Failed - expected truncation, got %v", err)
+	}
+	if stats != (Stats{Dirs: 1, Files: 2, Bytes: 3}) {
+		t.Errorf("test for budget Failed - unexpected stats %+v", stats)
+	}
+	var tree map[string]any
+	if err := json.Unmarshal(out.Bytes(), &tree); err != nil {
+		t.Errorf("test for budget Failed - partial tree is not valid JSON: %v\n%s", err, out)
+	}
+
+	out.Reset()
+	_, err = TreeFS(out, fsys, ".", Options{PrintFiles: true, MaxEntries: 5})
+	if err != nil {
+		t.Errorf("test for exact budget Failed - error: %v", err)
+	}
+}
+
+type cancelingRenderer struct {
+	recordingRenderer
+	cancel context.CancelFunc
+}
+
+func (r *cancelingRenderer) BeginDir(entry Entry) {
+	r.recordingRenderer.BeginDir(entry)
+	if entry.Depth == 1 {
+		r.cancel()
+	}
+}
+
+func TestTreeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelingRenderer{cancel: cancel}
+	_, err := TreeContext(ctx, nil, "../testdata", Options{PrintFiles: true, Renderer: r})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("test for cancel Failed - expected context.Canceled, got %v", err)
+	}
+	expected := "begin ../testdata 0,begin project 1,file file.txt 2,file gopher.png 2,end project 1,end ../testdata 0"
+	if result := strings.Join(r.events, ","); result != expected {
+		t.Errorf("test for cancel Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	_, err = TreeContext(ctx, io.Discard, "../testdata", Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("test for canceled context Failed - expected context.Canceled, got %v", err)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	cases := map[string]ByteSize{"0": 0, "512": 512, "10K": 10240, "1.5m": 1572864, "2G": 2 << 30}
+	for value, expected := range cases {
+		var size ByteSize
+		if err := size.Set(value); err != nil || size != expected {
+			t.Errorf("test for %q Failed - got %d, %v", value, size, err)
+		}
+	}
+	for _, value := range []string{"", "K", "-1", "ten", "ı", "ſ", "1ſ"} {
+		var size ByteSize
+		if err := size.Set(value); err == nil {
+			t.Errorf("test for %q Failed - expected error", value)
+		}
+	}
+}
+
+type fakeWatcher struct {
+	added []string
+}
+
+func (w *fakeWatcher) add(name string) error {
+	w.added = append(w.added, name)
+	return nil
+}
+
+func (w *fakeWatcher) remove(name string) {}
+
+func (w *fakeWatcher) changes() ([]string, []string, error) {
+	return nil, nil, errors.New("no changes")
+}
+
+func (w *fakeWatcher) close() error { return nil }
+
+func TestWatchFS(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a/one.txt": {Data: []byte("1")},
+		"b/two.txt": {Data: []byte("22")},
+	}
+	watcher := &fakeWatcher{}
+	fsys := newWatchFS(mapFS, watcher)
+
+	out := new(bytes.Buffer)
+	if _, err := TreeFS(out, fsys, ".", Options{PrintFiles: true}); err != nil {
+		t.Fatalf("test for first rendering Failed - error: %v", err)
+	}
+	if strings.Join(watcher.added, " ") != ". a b" {
+		t.Errorf("test for watches Failed - got %v", watcher.added)
+	}
+
+	mapFS["b/three.txt"] = &fstest.MapFile{Data: []byte("333")}
+	fsys.forget("b")
+	out.Reset()
+	if _, err := TreeFS(out, fsys, ".", Options{PrintFiles: true}); err != nil {
+		t.Fatalf("test for second rendering Failed - error: %v", err)
+	}
+	expected := "├───a\n│\t└───one.txt (1b)\n└───b\n\t├───three.txt (3b)\n\t└───two.txt (2b)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("test for second rendering Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if strings.Join(watcher.added, " ") != ". a b b" {
+		t.Errorf("test for reread Failed - only b should be read again, got %v", watcher.added)
+	}
+
+	var events []string
+	mapFS["a/sub/four.txt"] = &fstest.MapFile{Data: []byte("4444")}
+	delete(mapFS, "a/one.txt")
+	fsys.update("a", Options{}, func(added bool, name string) {
+		events = append(events, fmt.Sprint(added, " ", name))
+	})
+	if strings.Join(events, ", ") != "true a/sub, true a/sub/four.txt, false a/one.txt" {
+		t.Errorf("test for events Failed - got %v", events)
+	}
+
+	ignoredFS := fstest.MapFS{
+		".gitignore": {Data: []byte("*.log\nbuild/\n")},
+		"src/a.go":   {Data: []byte("package a")},
+	}
+	watcher = &fakeWatcher{}
+	fsys = newWatchFS(ignoredFS, watcher)
+	opts := Options{PrintFiles: true, GitIgnore: true, MaxDepth: 1}
+	if _, err := TreeFS(io.Discard, fsys, ".", opts); err != nil {
+		t.Fatalf("test for ignored rendering Failed - error: %v", err)
+	}
+	ignoredFS["app.log"] = &fstest.MapFile{Data: []byte("log")}
+	ignoredFS["build/x/o.bin"] = &fstest.MapFile{Data: []byte("bin")}
+	ignoredFS["docs/deep/guide.md"] = &fstest.MapFile{Data: []byte("# Guide")}
+	ignoredFS["keep.txt"] = &fstest.MapFile{Data: []byte("keep")}
+	events = nil
+	fsys.update(".", opts, func(added bool, name string) {
+		events = append(events, fmt.Sprint(added, " ", name))
+	})
+	if strings.Join(events, ", ") != "true docs, true keep.txt" {
+		t.Errorf("test for ignored events Failed - got %v", events)
+	}
+	if strings.Join(watcher.added, " ") != ". ." {
+		t.Errorf("test for ignored watches Failed - got %v", watcher.added)
+	}
+}
+
+func TestWatchTreeEvents(t *testing.T) {
+	root := t.TempDir()
+	if watcher, err := newDirWatcher(root); err != nil {
+		t.Skip(err)
+	} else {
+		watcher.close()
+	}
+	os.Mkdir(filepath.Join(root, "drop"), 0755)
+
+	reader, writer := io.Pipe()
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(writer, root, Options{PrintFiles: true, WatchEvents: true}, stop, nil)
+		writer.Close()
+	}()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	expect := func(expected string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("test for watch Failed - got %q, expected %q", line, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("test for watch Failed - timed out waiting for %q", expected)
+		}
+	}
+
+	expect("└───drop")
+	os.WriteFile(filepath.Join(root, "drop", "batch.csv"), []byte("a,b"), 0644)
+	expect("+ " + filepath.Join(root, "drop", "batch.csv"))
+	os.Remove(filepath.Join(root, "drop", "batch.csv"))
+	expect("- " + filepath.Join(root, "drop", "batch.csv"))
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("test for stop Failed - error: %v", err)
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "fixture")
+	entries, err := ParseText(strings.NewReader(testDataResult))
+	if err != nil {
+		t.Fatalf("test for parse Failed - error: %v", err)
+	}
+	entries = append(entries, SpecEntry{Path: "link", Target: "zline/empty.txt"})
+	if err := Build(root, entries); err != nil {
+		t.Fatalf("test for build Failed - error: %v", err)
+	}
+
+	out := new(bytes.Buffer)
+	if _, err := Tree(out, root, Options{PrintFiles: true}); err != nil {
+		t.Fatalf("test for render Failed - error: %v", err)
+	}
+	expected := strings.Replace(testDataResult, "├───project", "├───link -> zline/empty.txt\n├───project", 1)
+	if result := out.String(); result != expected {
+		t.Errorf("test for round trip Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, "project", "gopher.png"))
+	if err != nil || len(content) != 70372 || bytes.Count(content, []byte{0}) != len(content) {
+		t.Errorf("test for zero-filled file Failed - got %d bytes, error: %v", len(content), err)
+	}
+}
+
+func TestBuildTreeBracketedNames(t *testing.T) {
+	source := t.TempDir()
+	os.MkdirAll(filepath.Join(source, "Photos (2019)"), 0755)
+	os.MkdirAll(filepath.Join(source, "old [v1]"), 0755)
+	os.WriteFile(filepath.Join(source, "Photos (2019)", "beach (1).jpg"), []byte("jpeg"), 0644)
+
+	out := new(bytes.Buffer)
+	if _, err := Tree(out, source, Options{PrintFiles: true}); err != nil {
+		t.Fatalf("test for render Failed - error: %v", err)
+	}
+	entries, err := ParseText(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("test for parse Failed - error: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "copy")
+	if err := Build(target, entries); err != nil {
+		t.Fatalf("test for build Failed - error: %v", err)
+	}
+
+	rebuilt := new(bytes.Buffer)
+	if _, err := Tree(rebuilt, target, Options{PrintFiles: true}); err != nil {
+		t.Fatalf("test for rebuilt render Failed - error: %v", err)
+	}
+	expected := "├───Photos (2019)\n│\t└───beach (1).jpg (4b)\n└───old [v1]\n"
+	if out.String() != expected || rebuilt.String() != expected {
+		t.Errorf("test for round trip Failed - got %q and %q, expected %q", out.String(), rebuilt.String(), expected)
+	}
+}
+
+func TestParseTreeTextErrors(t *testing.T) {
+	cases := []string{
+		"file.txt\n",
+		"└───a\n\t\t└───b\n",
+		"└───a (1b)\n\t└───b\n",
+		"└───..\n",
+		"└───a/b (empty)\n",
+		"└───main.go (vary)\n",
+		"└───gopher.png (68.7K)\n",
+		"└───b [error opening dir]\n",
+		"└───latest -> main.go [recursive, not followed]\n",
+		"└───new.txt [added] (5b)\n",
+		"└───notes.txt [changed] (2b -> 14b)\n",
+		"└───big [3 entries, over the file limit]\n",
+		"└───… (2 more entries)\n",
+		"└───logs (1.2M)\n",
+	}
+	for _, text := range cases {
+		if _, err := ParseText(strings.NewReader(text)); err == nil {
+			t.Errorf("test for %q Failed - expected error", text)
+		}
+	}
+}
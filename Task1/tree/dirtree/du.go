@@ -0,0 +1,47 @@
+package dirtree
+
+type deferredEvent struct {
+	isDir bool
+	isEnd bool
+	entry Entry
+}
+
+// duRenderer holds back every event until the walk is over so that
+// directory entries can carry the cumulative size and the digest of their
+// contents, which are only known once endDir has been reached.
+type duRenderer struct {
+	r      Renderer
+	events []deferredEvent
+	open   []int
+}
+
+func (d *duRenderer) BeginDir(entry Entry) {
+	d.open = append(d.open, len(d.events))
+	d.events = append(d.events, deferredEvent{isDir: true, entry: entry})
+}
+
+func (d *duRenderer) File(entry Entry) {
+	d.events = append(d.events, deferredEvent{entry: entry})
+}
+
+func (d *duRenderer) EndDir(entry Entry) {
+	begin := d.open[len(d.open)-1]
+	d.open = d.open[:len(d.open)-1]
+	d.events[begin].entry.Size = entry.Size
+	d.events[begin].entry.Digest = entry.Digest
+	d.events = append(d.events, deferredEvent{isDir: true, isEnd: true, entry: entry})
+}
+
+func (d *duRenderer) Summary(stats Stats) {
+	for _, event := range d.events {
+		switch {
+		case event.isEnd:
+			d.r.EndDir(event.entry)
+		case event.isDir:
+			d.r.BeginDir(event.entry)
+		default:
+			d.r.File(event.entry)
+		}
+	}
+	d.r.Summary(stats)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package dirtree
+
+import "os"
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func getFileID(fileInfo os.FileInfo) (fileID, bool) {
+	id, ok := fileInfo.Sys().(fileID)
+	return id, ok
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package dirtree
+
+import (
+	"os"
+	"syscall"
+)
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func getFileID(fileInfo os.FileInfo) (fileID, bool) {
+	if id, ok := fileInfo.Sys().(fileID); ok {
+		return id, true
+	}
+
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
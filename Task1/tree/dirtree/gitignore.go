@@ -0,0 +1,175 @@
+package dirtree
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const gitIgnoreFile = ".gitignore"
+
+type gitIgnoreRule struct {
+	base     string
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// gitIgnore holds the rules of every .gitignore file between the root of the
+// walk and the current directory, outermost first. It is never modified once
+// built, so listings read ahead on the worker pool can share it.
+type gitIgnore struct {
+	rules []gitIgnoreRule
+}
+
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return re.String()
+}
+
+func parseGitIgnoreLine(base string, line string) (gitIgnoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitIgnoreRule{}, false
+	}
+
+	rule := gitIgnoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return gitIgnoreRule{}, false
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return gitIgnoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+func parseGitIgnore(base string, content []byte) []gitIgnoreRule {
+	var rules []gitIgnoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if rule, ok := parseGitIgnoreLine(base, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// withDir returns the rules that apply inside dir, which is given relative to
+// the root of the walk ("" for the root itself).
+func (ignore *gitIgnore) withDir(fsys fs.FS, fsPath string, dir string) *gitIgnore {
+	content, err := fs.ReadFile(fsys, joinPath(fsPath, gitIgnoreFile))
+	if err != nil {
+		return ignore
+	}
+	rules := parseGitIgnore(dir, content)
+	if len(rules) == 0 {
+		return ignore
+	}
+
+	combined := &gitIgnore{}
+	if ignore != nil {
+		combined.rules = append(combined.rules, ignore.rules...)
+	}
+	combined.rules = append(combined.rules, rules...)
+	return combined
+}
+
+func (ignore *gitIgnore) isIgnored(relPath string, isDir bool) bool {
+	if ignore == nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range ignore.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		subject := relPath
+		if rule.base != "" {
+			if !strings.HasPrefix(relPath, rule.base+"/") {
+				continue
+			}
+			subject = relPath[len(rule.base)+1:]
+		}
+		if !rule.anchored {
+			subject = path.Base(subject)
+		}
+
+		if rule.re.MatchString(subject) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (ignore *gitIgnore) filter(dir string, filesInDirInfo []os.FileInfo) []os.FileInfo {
+	if ignore == nil {
+		return filesInDirInfo
+	}
+
+	filtered := filesInDirInfo[:0]
+	for _, fileInfo := range filesInDirInfo {
+		if !ignore.isIgnored(path.Join(dir, fileInfo.Name()), fileInfo.IsDir()) {
+			filtered = append(filtered, fileInfo)
+		}
+	}
+	return filtered
+}
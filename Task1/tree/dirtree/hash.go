@@ -0,0 +1,183 @@
+package dirtree
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// HashAlgorithm is the checksum used for file and directory digests. The
+// zero value computes no digests.
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "sha256"
+	HashCRC32  HashAlgorithm = "crc32"
+	HashMD5    HashAlgorithm = "md5"
+)
+
+func (algorithm *HashAlgorithm) String() string {
+	return string(*algorithm)
+}
+
+func (algorithm *HashAlgorithm) Set(value string) error {
+	switch HashAlgorithm(value) {
+	case HashSHA256, HashCRC32, HashMD5:
+		*algorithm = HashAlgorithm(value)
+		return nil
+	}
+	return fmt.Errorf("unknown hash algorithm %q", value)
+}
+
+func (algorithm HashAlgorithm) new() hash.Hash {
+	switch algorithm {
+	case HashCRC32:
+		return crc32.NewIEEE()
+	case HashMD5:
+		return md5.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// Digest is the checksum of a file or directory that is computed in
+// the background; sum and err may only be read after done is closed.
+type Digest struct {
+	done chan struct{}
+	sum  []byte
+	err  error
+}
+
+func (digest *Digest) String() string {
+	if digest == nil {
+		return "-"
+	}
+	<-digest.done
+	if digest.err != nil {
+		return "?"
+	}
+	return hex.EncodeToString(digest.sum)
+}
+
+// hasher checksums file contents on a pool of workers. Directory digests are
+// Merkle-style: they hash the names, types and digests of the children, so
+// two directories get the same digest only if their whole subtrees match.
+type hasher struct {
+	fsys      fs.FS
+	algorithm HashAlgorithm
+	pool      *readPool
+	pending   sync.WaitGroup
+	mu        sync.Mutex
+	errs      []error
+}
+
+func newHasher(fsys fs.FS, algorithm HashAlgorithm, workers int) *hasher {
+	return &hasher{fsys: fsys, algorithm: algorithm, pool: newReadPool(workers)}
+}
+
+func (h *hasher) close() {
+	h.pool.close()
+}
+
+func (h *hasher) sumFile(path string, fileInfo os.FileInfo) ([]byte, error) {
+	sum := h.algorithm.new()
+	if isUnfollowedLink(fileInfo) {
+		io.WriteString(sum, linkTarget(fileInfo))
+		return sum.Sum(nil), nil
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return sum.Sum(nil), nil
+	}
+
+	file, err := h.fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(sum, file); err != nil {
+		return nil, err
+	}
+	return sum.Sum(nil), nil
+}
+
+func (h *hasher) hashFile(path string, fileInfo os.FileInfo) *Digest {
+	digest := &Digest{done: make(chan struct{})}
+	h.pending.Add(1)
+	h.pool.jobs <- func() {
+		defer h.pending.Done()
+		digest.sum, digest.err = h.sumFile(path, fileInfo)
+		if digest.err != nil {
+			h.mu.Lock()
+			h.errs = append(h.errs, digest.err)
+			h.mu.Unlock()
+		}
+		close(digest.done)
+	}
+	return digest
+}
+
+// hashDir combines the digests of the entries of a directory, given in the
+// same order as filesInDirInfo. Entries without a digest, such as links that
+// loop back to an ancestor, contribute only their name and type.
+func (h *hasher) hashDir(filesInDirInfo []os.FileInfo, digests []*Digest) *Digest {
+	order := make([]int, len(filesInDirInfo))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return filesInDirInfo[order[a]].Name() < filesInDirInfo[order[b]].Name()
+	})
+
+	digest := &Digest{done: make(chan struct{})}
+	go func() {
+		defer close(digest.done)
+		sum := h.algorithm.new()
+		for _, i := range order {
+			kind := "f"
+			if filesInDirInfo[i].IsDir() {
+				kind = "d"
+			}
+			fmt.Fprintf(sum, "%s %s\x00", kind, filesInDirInfo[i].Name())
+			if child := digests[i]; child != nil {
+				<-child.done
+				if child.err != nil {
+					digest.err = child.err
+					return
+				}
+				sum.Write(child.sum)
+			}
+		}
+		digest.sum = sum.Sum(nil)
+	}()
+	return digest
+}
+
+// wait blocks until every file has been hashed and returns the errors met
+// on the way.
+func (h *hasher) wait() []error {
+	h.pending.Wait()
+	return h.errs
+}
+
+func (w *treeWalker) hashFiles(path string, filesInDirInfo []os.FileInfo) []*Digest {
+	if w.hasher == nil {
+		return nil
+	}
+
+	digests := make([]*Digest, len(filesInDirInfo))
+	for i, fileInfo := range filesInDirInfo {
+		if !fileInfo.IsDir() {
+			digests[i] = w.hasher.hashFile(joinPath(path, fileInfo.Name()), fileInfo)
+		}
+	}
+	return digests
+}
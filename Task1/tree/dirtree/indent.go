@@ -0,0 +1,149 @@
+package dirtree
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// IndentStyle selects whether the box formats indent with tabs or spaces.
+// The zero value keeps the style of the format.
+type IndentStyle string
+
+const (
+	IndentTabs   IndentStyle = "tabs"
+	IndentSpaces IndentStyle = "spaces"
+)
+
+func (style *IndentStyle) String() string {
+	return string(*style)
+}
+
+func (style *IndentStyle) Set(value string) error {
+	switch IndentStyle(value) {
+	case IndentTabs, IndentSpaces:
+		*style = IndentStyle(value)
+		return nil
+	}
+	return fmt.Errorf("unknown indentation style %q", value)
+}
+
+// Glyphs are the characters the box formats draw the tree with: Tee and
+// Corner start the line of an entry that is followed by a sibling or is the
+// last one, Horizontal pads the connector and Vertical continues the line of
+// an open directory.
+type Glyphs struct {
+	Tee        string
+	Corner     string
+	Horizontal string
+	Vertical   string
+}
+
+// Built-in glyph sets: ClassicGlyphs draw the text and unicode formats,
+// ASCIIGlyphs the ascii one.
+var (
+	ClassicGlyphs = Glyphs{Tee: "├", Corner: "└", Horizontal: "─", Vertical: "│"}
+	ASCIIGlyphs   = Glyphs{Tee: "|", Corner: "`", Horizontal: "-", Vertical: "|"}
+)
+
+var namedGlyphs = map[string]Glyphs{
+	"classic": ClassicGlyphs,
+	"ascii":   ASCIIGlyphs,
+}
+
+func (glyphs *Glyphs) String() string {
+	for name, known := range namedGlyphs {
+		if *glyphs == known {
+			return name
+		}
+	}
+	return glyphs.Tee + glyphs.Corner + glyphs.Horizontal + glyphs.Vertical
+}
+
+// Set accepts the name of a built-in glyph set or four characters giving
+// the tee, corner, horizontal and vertical glyphs in that order.
+func (glyphs *Glyphs) Set(value string) error {
+	if known, ok := namedGlyphs[value]; ok {
+		*glyphs = known
+		return nil
+	}
+	if utf8.RuneCountInString(value) != 4 {
+		return fmt.Errorf("invalid glyph set %q: expected classic, ascii or four characters", value)
+	}
+	runes := []rune(value)
+	*glyphs = Glyphs{Tee: string(runes[0]), Corner: string(runes[1]), Horizontal: string(runes[2]), Vertical: string(runes[3])}
+	return nil
+}
+
+// prefixSet holds the strings a box renderer puts in front of an entry: the
+// connector of the entry itself and the indention it adds for the children
+// of a directory that has more siblings below it (vertical) or not (blank).
+type prefixSet struct {
+	branch     string
+	lastBranch string
+	vertical   string
+	blank      string
+}
+
+// newPrefixSet builds the prefixes for one level of indentation: width tabs,
+// or width columns of spaces with the connector padded to the same width.
+// Connectors always leave a space before the name, so with glyphs a level
+// of spaces is at least two columns wide. Zero glyphs draw no connectors.
+func newPrefixSet(glyphs Glyphs, style IndentStyle, width int) prefixSet {
+	if style == IndentTabs {
+		unit := strings.Repeat("\t", width)
+		if glyphs == (Glyphs{}) {
+			return prefixSet{vertical: unit, blank: unit}
+		}
+		return prefixSet{
+			branch:     glyphs.Tee + strings.Repeat(glyphs.Horizontal, 3),
+			lastBranch: glyphs.Corner + strings.Repeat(glyphs.Horizontal, 3),
+			vertical:   glyphs.Vertical + unit,
+			blank:      unit,
+		}
+	}
+
+	if glyphs == (Glyphs{}) {
+		unit := strings.Repeat(" ", width)
+		return prefixSet{vertical: unit, blank: unit}
+	}
+	width = max(width, 2)
+	unit := strings.Repeat(" ", width)
+	fill := strings.Repeat(glyphs.Horizontal, width-2) + " "
+	return prefixSet{
+		branch:     glyphs.Tee + fill,
+		lastBranch: glyphs.Corner + fill,
+		vertical:   glyphs.Vertical + unit[1:],
+		blank:      unit,
+	}
+}
+
+// boxPrefixes returns the prefixes of a box format, with the glyphs and the
+// indentation of opts replacing the defaults of the format where set.
+func boxPrefixes(opts Options) prefixSet {
+	glyphs, style, width := ClassicGlyphs, IndentTabs, 1
+	switch opts.Format {
+	case FormatASCII:
+		glyphs, style, width = ASCIIGlyphs, IndentSpaces, 4
+	case FormatUnicode:
+		style, width = IndentSpaces, 4
+	case FormatIndent:
+		glyphs = Glyphs{}
+	}
+
+	if opts.Glyphs != (Glyphs{}) && opts.Format != FormatIndent {
+		glyphs = opts.Glyphs
+	}
+	if opts.IndentStyle != "" && opts.IndentStyle != style {
+		style, width = opts.IndentStyle, 1
+		if style == IndentSpaces {
+			width = 4
+		}
+	}
+	if opts.IndentWidth > 0 {
+		width = opts.IndentWidth
+	}
+	return newPrefixSet(glyphs, style, width)
+}
+
+var classicPrefixes = newPrefixSet(ClassicGlyphs, IndentTabs, 1)
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package dirtree
+
+import "os"
+
+func getFileOwner(fileInfo os.FileInfo) (uid uint32, gid uint32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package dirtree
+
+import (
+	"os"
+	"syscall"
+)
+
+func getFileOwner(fileInfo os.FileInfo) (uid uint32, gid uint32, ok bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
@@ -0,0 +1,87 @@
+package dirtree
+
+import "os"
+
+// readPool reads directories ahead of the walker on a fixed number of
+// goroutines. The walker still consumes the listings one by one in the order
+// it renders them, so the output does not depend on which read finishes first.
+type readPool struct {
+	jobs chan func()
+	done chan struct{}
+}
+
+func newReadPool(workers int) *readPool {
+	pool := &readPool{jobs: make(chan func()), done: make(chan struct{})}
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (pool *readPool) work() {
+	for {
+		select {
+		case job := <-pool.jobs:
+			job()
+		case <-pool.done:
+			return
+		}
+	}
+}
+
+func (pool *readPool) close() {
+	close(pool.done)
+}
+
+// walksHidden reports whether subtrees that are not printed still have to be
+// walked for their cumulative sizes or digests.
+func (w *treeWalker) walksHidden() bool {
+	return w.opts.DiskUsage || w.opts.Hash != ""
+}
+
+func (w *treeWalker) shouldDescend(fileInfo os.FileInfo, depth int) bool {
+	return fileInfo.IsDir() && (w.isVisible(depth+1) || w.walksHidden())
+}
+
+func (w *treeWalker) prefetchSubdirs(path string, parent dirListing, depth int) map[int]chan dirListing {
+	if w.pool == nil || w.opts.Prune {
+		return nil
+	}
+
+	listings := make(map[int]chan dirListing)
+	var paths []string
+	var indexes []int
+	for i, fileInfo := range parent.filesInDirInfo {
+		if w.shouldDescend(fileInfo, depth) {
+			listings[i] = make(chan dirListing, 1)
+			paths = append(paths, joinPath(path, fileInfo.Name()))
+			indexes = append(indexes, i)
+		}
+	}
+
+	go func() {
+		for n, childPath := range paths {
+			childPath, listing := childPath, listings[indexes[n]]
+			job := func() {
+				listing <- w.readDirEntries(childPath, parent.ignore)
+			}
+			select {
+			case w.pool.jobs <- job:
+			case <-w.pool.done:
+				return
+			}
+		}
+	}()
+	return listings
+}
+
+func (w *treeWalker) listDir(path string, parentIgnore *gitIgnore, listing chan dirListing) dirListing {
+	if cached, ok := w.readAhead[path]; ok {
+		delete(w.readAhead, path)
+		return cached
+	}
+	if listing == nil {
+		return w.readDirEntries(path, parentIgnore)
+	}
+	return <-listing
+}
@@ -0,0 +1,59 @@
+package dirtree
+
+import "os"
+
+// pruneEmptyDirs drops the subdirectories of listing that would not lead to
+// any listed file. It has to read them before the first entry of path is
+// rendered, so that the last entry, and with it the └─── connector, is known;
+// the listings read on the way are kept for the walk to pick up.
+func (w *treeWalker) pruneEmptyDirs(path string, listing dirListing, depth int) []os.FileInfo {
+	kept := make([]os.FileInfo, 0, len(listing.filesInDirInfo))
+	for _, fileInfo := range listing.filesInDirInfo {
+		if !fileInfo.IsDir() || w.holdsFiles(joinPath(path, fileInfo.Name()), fileInfo, listing.ignore, depth+1) {
+			kept = append(kept, fileInfo)
+		}
+	}
+	return kept
+}
+
+// holdsFiles reports whether the directory at path has a file that passes
+// the filters somewhere in its subtree, looking no deeper than the entries
+// that would be printed. Files count whether or not they are printed, so
+// without PrintFiles a directory holding only files is kept as a leaf.
+// Directories that cannot be read are kept so that the walk reports them.
+func (w *treeWalker) holdsFiles(path string, fileInfo os.FileInfo, parentIgnore *gitIgnore, depth int) bool {
+	if !w.isVisible(depth) {
+		return false
+	}
+	id, hasID := getFileID(fileInfo)
+	if hasID && w.ancestors[id] {
+		return false
+	}
+
+	listing, ok := w.readAhead[path]
+	if !ok {
+		listing = w.readDirEntries(path, parentIgnore)
+		w.readAhead[path] = listing
+	}
+	if listing.err != nil {
+		return true
+	}
+
+	for _, childInfo := range listing.filesInDirInfo {
+		if !childInfo.IsDir() {
+			return true
+		}
+	}
+
+	if hasID {
+		w.ancestors[id] = true
+		defer delete(w.ancestors, id)
+	}
+	for _, childInfo := range listing.filesInDirInfo {
+		if w.holdsFiles(joinPath(path, childInfo.Name()), childInfo, listing.ignore, depth+1) {
+			return true
+		}
+	}
+	delete(w.readAhead, path)
+	return false
+}
@@ -0,0 +1,310 @@
+package dirtree
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+)
+
+// Format selects the built-in renderer. The zero value is FormatText.
+type Format string
+
+const (
+	FormatText     Format = "text"
+	FormatASCII    Format = "ascii"
+	FormatUnicode  Format = "unicode"
+	FormatIndent   Format = "indent"
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatXML      Format = "xml"
+)
+
+var outputFormats = []Format{
+	FormatText, FormatASCII, FormatUnicode, FormatIndent,
+	FormatHTML, FormatMarkdown, FormatJSON, FormatXML,
+}
+
+func (format *Format) String() string {
+	return string(*format)
+}
+
+func (format *Format) Set(value string) error {
+	for _, known := range outputFormats {
+		if Format(value) == known {
+			*format = known
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q", value)
+}
+
+// Entry is a file or directory passed to a Renderer. RelPath is relative to
+// the root of the walk, and Size is the size of the whole subtree for
+// directories in disk usage mode.
+//
+// Entries left out by Options.FileLimit are replaced by a single entry
+// passed to File, with no Info and Omitted set to their number. A directory
+// skipped for exceeding the limit has Omitted set to its number of entries.
+type Entry struct {
+	Name       string
+	RelPath    string
+	Info       os.FileInfo
+	Depth      int
+	IsLast     bool
+	Size       int64
+	LinkTarget string
+	Recursive  bool
+	ReadErr    error
+	Change     Change
+	OldSize    int64
+	Digest     *Digest
+	Omitted    int
+}
+
+const elisionName = "…"
+
+func isElision(entry Entry) bool {
+	return entry.Info == nil && entry.Omitted > 0
+}
+
+func entryLabel(entry Entry) string {
+	label := entry.Name
+	if entry.LinkTarget != "" {
+		label += " -> " + entry.LinkTarget
+	}
+	if entry.Recursive {
+		label += " [recursive, not followed]"
+	}
+	if entry.ReadErr != nil {
+		label += " [error opening dir]"
+	}
+	if entry.Change != "" {
+		label += " [" + string(entry.Change) + "]"
+	}
+	if entry.Omitted > 0 {
+		label += " [" + pluralize(int64(entry.Omitted), "entry", "entries") + ", over the file limit]"
+	}
+	return label
+}
+
+func entryFileLabel(entry Entry, opts Options) string {
+	if isElision(entry) {
+		return fmt.Sprintf("%s (%s)", entry.Name, pluralize(int64(entry.Omitted), "more entry", "more entries"))
+	}
+	if isUnfollowedLink(entry.Info) {
+		return entryLabel(entry)
+	}
+	if entry.Change == Changed && entry.Info.Mode().IsRegular() {
+		oldEntry := entry
+		oldEntry.Size = entry.OldSize
+		return fmt.Sprintf("%s (%s -> %s)", entryLabel(entry), getSizeString(oldEntry, opts), getSizeString(entry, opts))
+	}
+	return fmt.Sprintf("%s (%s)", entryLabel(entry), getSizeString(entry, opts))
+}
+
+func entryDirLabel(entry Entry, opts Options) string {
+	if !opts.DiskUsage || entry.Recursive {
+		return entryLabel(entry)
+	}
+	return fmt.Sprintf("%s (%s)", entryLabel(entry), getSizeString(entry, opts))
+}
+
+// Stats counts the directories and files that were listed.
+type Stats struct {
+	Dirs  int
+	Files int
+	Bytes int64
+}
+
+func pluralize(count int64, singular string, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+func (stats Stats) String() string {
+	return pluralize(int64(stats.Dirs), "directory", "directories") + ", " +
+		pluralize(int64(stats.Files), "file", "files") + ", " +
+		pluralize(stats.Bytes, "byte", "bytes") + " total"
+}
+
+// footerLines returns the lines the text formats print after the tree: the
+// digest of the root, in the layout of sha256sum, and the report.
+func footerLines(root Entry, stats Stats, opts Options) []string {
+	var lines []string
+	if root.Digest != nil {
+		lines = append(lines, root.Digest.String()+"  "+root.Name)
+	}
+	if opts.Report {
+		lines = append(lines, stats.String())
+	}
+	return lines
+}
+
+// Renderer receives the walk as a stream of events. The root directory is
+// passed to BeginDir and EndDir with depth 0, its children with depth 1 and
+// so on; Summary is called once after the root has been closed.
+type Renderer interface {
+	BeginDir(entry Entry)
+	File(entry Entry)
+	EndDir(entry Entry)
+	Summary(stats Stats)
+}
+
+// NewRenderer returns the built-in renderer for opts.Format writing to out.
+func NewRenderer(out io.Writer, opts Options) Renderer {
+	switch opts.Format {
+	case FormatHTML:
+		return &htmlRenderer{out: out, opts: opts, columns: newColumnFormatter(opts)}
+	case FormatMarkdown:
+		return &markdownRenderer{out: out, opts: opts, columns: newColumnFormatter(opts)}
+	case FormatJSON:
+		return &jsonRenderer{out: out}
+	case FormatXML:
+		return &xmlRenderer{out: out}
+	default:
+		return &boxRenderer{out: out, prefixes: boxPrefixes(opts), opts: opts, columns: newColumnFormatter(opts), colors: newColorizer(opts)}
+	}
+}
+
+func (prefixes prefixSet) connector(isLast bool) string {
+	if isLast {
+		return prefixes.lastBranch
+	}
+	return prefixes.branch
+}
+
+func printEntry(out io.Writer, indention string, prefixes prefixSet, columns string, label string, isLast bool) {
+	fmt.Fprintf(out, "%s%s%s%s\n", indention, prefixes.connector(isLast), columns, label)
+}
+
+type boxRenderer struct {
+	out        io.Writer
+	prefixes   prefixSet
+	opts       Options
+	columns    *columnFormatter
+	colors     *colorizer
+	indentions []string
+	root       Entry
+}
+
+func (r *boxRenderer) indention() string {
+	if len(r.indentions) == 0 {
+		return ""
+	}
+	return r.indentions[len(r.indentions)-1]
+}
+
+// paintName colors the name at the start of a label built from the plain
+// entry, so that the size mask still sees the real name.
+func (r *boxRenderer) paintName(entry Entry, label string) string {
+	return r.colors.paint(entry) + strings.TrimPrefix(label, entry.Name)
+}
+
+func (r *boxRenderer) BeginDir(entry Entry) {
+	if entry.Depth == 0 {
+		return
+	}
+
+	indention := r.indention()
+	printEntry(r.out, indention, r.prefixes, r.columns.format(entry), r.paintName(entry, entryDirLabel(entry, r.opts)), entry.IsLast)
+
+	if entry.IsLast {
+		r.indentions = append(r.indentions, indention+r.prefixes.blank)
+		return
+	}
+	r.indentions = append(r.indentions, indention+r.prefixes.vertical)
+}
+
+func (r *boxRenderer) File(entry Entry) {
+	printEntry(r.out, r.indention(), r.prefixes, r.columns.format(entry), r.paintName(entry, entryFileLabel(entry, r.opts)), entry.IsLast)
+}
+
+func (r *boxRenderer) EndDir(entry Entry) {
+	if entry.Depth == 0 {
+		r.root = entry
+		return
+	}
+	r.indentions = r.indentions[:len(r.indentions)-1]
+}
+
+func (r *boxRenderer) Summary(stats Stats) {
+	if lines := footerLines(r.root, stats, r.opts); len(lines) > 0 {
+		fmt.Fprintf(r.out, "\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+type markdownRenderer struct {
+	out     io.Writer
+	opts    Options
+	columns *columnFormatter
+	root    Entry
+}
+
+func (r *markdownRenderer) writeItem(entry Entry, text string) {
+	fmt.Fprintf(r.out, "%s- %s%s\n", strings.Repeat("  ", entry.Depth-1), r.columns.format(entry), text)
+}
+
+func (r *markdownRenderer) BeginDir(entry Entry) {
+	if entry.Depth == 0 {
+		return
+	}
+	r.writeItem(entry, entryDirLabel(entry, r.opts)+"/")
+}
+
+func (r *markdownRenderer) File(entry Entry) {
+	r.writeItem(entry, entryFileLabel(entry, r.opts))
+}
+
+func (r *markdownRenderer) EndDir(entry Entry) {
+	if entry.Depth == 0 {
+		r.root = entry
+	}
+}
+
+func (r *markdownRenderer) Summary(stats Stats) {
+	if lines := footerLines(r.root, stats, r.opts); len(lines) > 0 {
+		fmt.Fprintf(r.out, "\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+type htmlRenderer struct {
+	out     io.Writer
+	opts    Options
+	columns *columnFormatter
+	root    Entry
+}
+
+func (r *htmlRenderer) writeLine(depth int, line string) {
+	io.WriteString(r.out, strings.Repeat("  ", depth)+line+"\n")
+}
+
+func (r *htmlRenderer) BeginDir(entry Entry) {
+	if entry.Depth > 0 {
+		r.writeLine(2*entry.Depth-1, `<li class="directory">`+html.EscapeString(r.columns.format(entry)+entryDirLabel(entry, r.opts)))
+	}
+	r.writeLine(2*entry.Depth, "<ul>")
+}
+
+func (r *htmlRenderer) File(entry Entry) {
+	r.writeLine(2*entry.Depth-1, `<li class="file">`+html.EscapeString(r.columns.format(entry)+entryFileLabel(entry, r.opts))+"</li>")
+}
+
+func (r *htmlRenderer) EndDir(entry Entry) {
+	r.writeLine(2*entry.Depth, "</ul>")
+	if entry.Depth > 0 {
+		r.writeLine(2*entry.Depth-1, "</li>")
+		return
+	}
+	r.root = entry
+}
+
+func (r *htmlRenderer) Summary(stats Stats) {
+	for _, line := range footerLines(r.root, stats, r.opts) {
+		r.writeLine(0, "<p>"+html.EscapeString(line)+"</p>")
+	}
+}
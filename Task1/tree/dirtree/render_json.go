@@ -0,0 +1,118 @@
+package dirtree
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+const jsonIndention = "  "
+
+// jsonEntry holds only strings and numbers, so that encoding it cannot
+// fail: the mtime is formatted here because time.Time refuses to marshal
+// years outside 0-9999, which any tar header can carry.
+type jsonEntry struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Target    string `json:"target,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Change    string `json:"change,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Omitted   int    `json:"omitted,omitempty"`
+	Size      int64  `json:"size"`
+	Mode      string `json:"mode"`
+	Mtime     string `json:"mtime"`
+}
+
+func entryType(entry Entry) string {
+	switch {
+	case entry.LinkTarget != "":
+		return "link"
+	case entry.Info.IsDir():
+		return "directory"
+	default:
+		return "file"
+	}
+}
+
+func newJSONEntry(entry Entry) jsonEntry {
+	var errorText, digest string
+	if entry.ReadErr != nil {
+		errorText = entry.ReadErr.Error()
+	}
+	if entry.Digest != nil {
+		digest = entry.Digest.String()
+	}
+	return jsonEntry{
+		Name:      entry.Name,
+		Type:      entryType(entry),
+		Target:    entry.LinkTarget,
+		Recursive: entry.Recursive,
+		Error:     errorText,
+		Change:    string(entry.Change),
+		Digest:    digest,
+		Omitted:   entry.Omitted,
+		Size:      entry.Size,
+		Mode:      entry.Info.Mode().String(),
+		Mtime:     entry.Info.ModTime().Format(time.RFC3339Nano),
+	}
+}
+
+// jsonRenderer ends an entry only when it knows what follows, so that a
+// walk cut short still leaves a valid document.
+type jsonRenderer struct {
+	out     io.Writer
+	pending bool
+}
+
+type jsonElision struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Omitted int    `json:"omitted"`
+}
+
+func (r *jsonRenderer) writeEntry(entry Entry, withChildren bool) {
+	var encoded []byte
+	if isElision(entry) {
+		encoded, _ = json.Marshal(jsonElision{Name: entry.Name, Type: "elision", Omitted: entry.Omitted})
+	} else {
+		encoded, _ = json.Marshal(newJSONEntry(entry))
+	}
+
+	if r.pending {
+		io.WriteString(r.out, ",\n")
+	}
+	io.WriteString(r.out, strings.Repeat(jsonIndention, entry.Depth))
+	if !withChildren {
+		r.out.Write(encoded)
+		r.pending = true
+		return
+	}
+	r.out.Write(encoded[:len(encoded)-1])
+	io.WriteString(r.out, `,"children":[`+"\n")
+	r.pending = false
+}
+
+func (r *jsonRenderer) BeginDir(entry Entry) {
+	r.writeEntry(entry, true)
+}
+
+func (r *jsonRenderer) File(entry Entry) {
+	r.writeEntry(entry, false)
+}
+
+func (r *jsonRenderer) EndDir(entry Entry) {
+	if r.pending {
+		io.WriteString(r.out, "\n")
+	}
+	io.WriteString(r.out, strings.Repeat(jsonIndention, entry.Depth)+"]}")
+	r.pending = true
+}
+
+func (r *jsonRenderer) Summary(stats Stats) {
+	if r.pending {
+		io.WriteString(r.out, "\n")
+	}
+}
@@ -0,0 +1,98 @@
+package dirtree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const xmlIndention = "  "
+
+type xmlRenderer struct {
+	out io.Writer
+}
+
+func (r *xmlRenderer) writeIndention(depth int) {
+	io.WriteString(r.out, strings.Repeat(xmlIndention, depth+1))
+}
+
+func (r *xmlRenderer) writeAttr(name string, value string) {
+	fmt.Fprintf(r.out, ` %s="`, name)
+	xml.EscapeText(r.out, []byte(value))
+	io.WriteString(r.out, `"`)
+}
+
+func (r *xmlRenderer) writeOpenTag(tag string, entry Entry) {
+	r.writeIndention(entry.Depth)
+	io.WriteString(r.out, "<"+tag)
+	r.writeAttr("name", entry.Name)
+	if entry.LinkTarget != "" {
+		r.writeAttr("target", entry.LinkTarget)
+	}
+	r.writeAttr("size", fmt.Sprint(entry.Size))
+	r.writeAttr("mode", entry.Info.Mode().String())
+	r.writeAttr("time", entry.Info.ModTime().Format(time.RFC3339))
+	if entry.ReadErr != nil {
+		r.writeAttr("error", entry.ReadErr.Error())
+	}
+	if entry.Change != "" {
+		r.writeAttr("change", string(entry.Change))
+	}
+	if entry.Digest != nil {
+		r.writeAttr("digest", entry.Digest.String())
+	}
+	if entry.Omitted > 0 {
+		r.writeAttr("omitted", fmt.Sprint(entry.Omitted))
+	}
+	io.WriteString(r.out, ">")
+}
+
+func xmlTag(entry Entry) string {
+	switch {
+	case entry.LinkTarget != "":
+		return "link"
+	case entry.Info.IsDir():
+		return "directory"
+	default:
+		return "file"
+	}
+}
+
+func (r *xmlRenderer) BeginDir(entry Entry) {
+	if entry.Depth == 0 {
+		io.WriteString(r.out, xml.Header)
+		io.WriteString(r.out, "<tree>\n")
+	}
+	r.writeOpenTag(xmlTag(entry), entry)
+	io.WriteString(r.out, "\n")
+}
+
+func (r *xmlRenderer) File(entry Entry) {
+	if isElision(entry) {
+		r.writeIndention(entry.Depth)
+		fmt.Fprintf(r.out, "<elision omitted=\"%d\"></elision>\n", entry.Omitted)
+		return
+	}
+	tag := xmlTag(entry)
+	r.writeOpenTag(tag, entry)
+	io.WriteString(r.out, "</"+tag+">\n")
+}
+
+func (r *xmlRenderer) EndDir(entry Entry) {
+	r.writeIndention(entry.Depth)
+	io.WriteString(r.out, "</"+xmlTag(entry)+">\n")
+}
+
+func (r *xmlRenderer) Summary(stats Stats) {
+	r.writeIndention(0)
+	io.WriteString(r.out, "<report>\n")
+	r.writeIndention(1)
+	fmt.Fprintf(r.out, "<directories>%d</directories>\n", stats.Dirs)
+	r.writeIndention(1)
+	fmt.Fprintf(r.out, "<files>%d</files>\n", stats.Files)
+	r.writeIndention(0)
+	io.WriteString(r.out, "</report>\n")
+	io.WriteString(r.out, "</tree>\n")
+}
@@ -0,0 +1,87 @@
+package dirtree
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	iecUnits = "KMGTPE"
+	siUnits  = "kMGTPE"
+)
+
+func humanizeSize(size int64, si bool) string {
+	base, units := int64(1024), iecUnits
+	if si {
+		base, units = 1000, siUnits
+	}
+	if size < base {
+		return fmt.Sprint(size) + "b"
+	}
+
+	value := float64(size) / float64(base)
+	unit := 0
+	for value >= float64(base) && unit < len(units)-1 {
+		value /= float64(base)
+		unit++
+	}
+	return fmt.Sprintf("%.1f%c", value, units[unit])
+}
+
+func isSizeMasked(pattern string, entry Entry) bool {
+	for _, alternative := range strings.Split(pattern, "|") {
+		subject := entry.Name
+		if strings.Contains(alternative, "/") {
+			subject = entry.RelPath
+		}
+		if matched, _ := filepath.Match(alternative, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func getSizeString(entry Entry, opts Options) string {
+	if opts.MaskPattern != "" && isSizeMasked(opts.MaskPattern, entry) {
+		return "vary"
+	}
+
+	if entry.Size == 0 {
+		return "empty"
+	}
+
+	if opts.HumanSizes || opts.SIUnits {
+		return humanizeSize(entry.Size, opts.SIUnits)
+	}
+	return fmt.Sprint(entry.Size) + "b"
+}
+
+// ByteSize is a size given on the command line, either in bytes or with one
+// of the IEC unit suffixes printed by -h (10K, 1.5M).
+type ByteSize int64
+
+func (size *ByteSize) String() string {
+	return fmt.Sprint(int64(*size))
+}
+
+func (size *ByteSize) Set(value string) error {
+	number, multiplier := value, 1.0
+	if last, width := utf8.DecodeLastRuneInString(value); width > 0 {
+		if unit := strings.IndexRune(iecUnits, unicode.ToUpper(last)); unit != -1 {
+			number = value[:len(value)-width]
+			multiplier = math.Pow(1024, float64(unit+1))
+		}
+	}
+
+	parsed, err := strconv.ParseFloat(number, 64)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*size = ByteSize(parsed * multiplier)
+	return nil
+}
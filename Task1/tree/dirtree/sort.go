@@ -0,0 +1,115 @@
+package dirtree
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SortMode orders the entries of each directory. The zero value sorts by
+// name, like SortByName.
+type SortMode string
+
+const (
+	SortByName    SortMode = "name"
+	SortByVersion SortMode = "version"
+	SortBySize    SortMode = "size"
+	SortByMtime   SortMode = "mtime"
+	SortNone      SortMode = "none"
+)
+
+var sortModes = []SortMode{SortByName, SortByVersion, SortBySize, SortByMtime, SortNone}
+
+func (mode *SortMode) String() string {
+	return string(*mode)
+}
+
+func (mode *SortMode) Set(value string) error {
+	for _, known := range sortModes {
+		if SortMode(value) == known {
+			*mode = known
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sort mode %q", value)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+
+		startA, startB := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+
+		numberA, numberB := trimZeros(a[startA:i]), trimZeros(b[startB:j])
+		if len(numberA) != len(numberB) {
+			return len(numberA) < len(numberB)
+		}
+		if numberA != numberB {
+			return numberA < numberB
+		}
+	}
+	return len(a)-i < len(b)-j
+}
+
+func trimZeros(number string) string {
+	for len(number) > 1 && number[0] == '0' {
+		number = number[1:]
+	}
+	return number
+}
+
+func entryLess(a, b os.FileInfo, mode SortMode) bool {
+	switch mode {
+	case SortByVersion:
+		if naturalLess(a.Name(), b.Name()) || naturalLess(b.Name(), a.Name()) {
+			return naturalLess(a.Name(), b.Name())
+		}
+	case SortBySize:
+		if a.Size() != b.Size() {
+			return a.Size() > b.Size()
+		}
+	case SortByMtime:
+		if !a.ModTime().Equal(b.ModTime()) {
+			return a.ModTime().After(b.ModTime())
+		}
+	}
+	return a.Name() < b.Name()
+}
+
+func sortEntries(filesInDirInfo []os.FileInfo, opts Options) {
+	if opts.Sort == SortNone && !opts.DirsFirst {
+		return
+	}
+
+	sort.SliceStable(filesInDirInfo, func(i, j int) bool {
+		a, b := filesInDirInfo[i], filesInDirInfo[j]
+		if opts.DirsFirst && a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		if opts.Sort == SortNone {
+			return false
+		}
+		if opts.ReverseSort {
+			a, b = b, a
+		}
+		return entryLess(a, b, opts.Sort)
+	})
+}
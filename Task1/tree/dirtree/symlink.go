@@ -0,0 +1,48 @@
+package dirtree
+
+import (
+	"io/fs"
+	"os"
+)
+
+type symlinkInfo struct {
+	os.FileInfo
+	name   string
+	target string
+}
+
+func (link *symlinkInfo) Name() string {
+	return link.name
+}
+
+func resolveSymlinks(fsys fs.FS, path string, filesInDirInfo []os.FileInfo, followLinks bool) {
+	for i, fileInfo := range filesInDirInfo {
+		if fileInfo.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		linkPath := joinPath(path, fileInfo.Name())
+		target, err := fs.ReadLink(fsys, linkPath)
+		if err != nil {
+			continue
+		}
+
+		if followLinks {
+			if targetInfo, err := fs.Stat(fsys, linkPath); err == nil {
+				fileInfo = targetInfo
+			}
+		}
+		filesInDirInfo[i] = &symlinkInfo{FileInfo: fileInfo, name: filesInDirInfo[i].Name(), target: target}
+	}
+}
+
+func linkTarget(fileInfo os.FileInfo) string {
+	if link, ok := fileInfo.(*symlinkInfo); ok {
+		return link.target
+	}
+	return ""
+}
+
+func isUnfollowedLink(fileInfo os.FileInfo) bool {
+	return fileInfo.Mode()&os.ModeSymlink != 0
+}
@@ -0,0 +1,546 @@
+// Package dirtree walks a directory, an archive or any fs.FS and renders it
+// as a tree, either in one of the built-in formats or through a Renderer.
+package dirtree
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Options controls which entries are listed and how they are printed. The
+// zero value lists directories only, hidden ones included, sorted by name,
+// in the classic text format, like the original dirTree.
+type Options struct {
+	// Renderer, if set, receives the walk instead of the renderer selected
+	// by Format, and the output writer is not used.
+	Renderer Renderer
+	Format   Format
+
+	// Filters.
+	PrintFiles     bool
+	HideHidden     bool
+	MaxDepth       int
+	IncludePattern string
+	ExcludePattern string
+	Extensions     string
+	MinSize        ByteSize
+	MaxSize        ByteSize
+	GitIgnore      bool
+	HideGitDir     bool
+	// Prune omits directories with no file passing the filters anywhere
+	// below them. As in GNU tree, files count even without PrintFiles, so
+	// a directory that holds only files is kept.
+	Prune bool
+	// FileLimit, if positive, lists at most that many entries of a
+	// directory followed by an elision line counting the rest, or with
+	// SkipLargeDirs lists directories over the limit without opening them.
+	FileLimit     int
+	SkipLargeDirs bool
+
+	// Sorting.
+	Sort        SortMode
+	DirsFirst   bool
+	ReverseSort bool
+
+	// Walking.
+	FollowLinks bool
+	KeepGoing   bool
+	Workers     int
+	WatchEvents bool
+	// MaxEntries, if positive, stops the walk once that many entries have
+	// been listed, keeping the partial tree and returning ErrTruncated.
+	MaxEntries int
+
+	// Indentation of the box formats: IndentStyle and IndentWidth default to
+	// one tab for text and indent and to four spaces for ascii and unicode,
+	// and zero Glyphs keep the glyphs of the format.
+	IndentStyle IndentStyle
+	IndentWidth int
+	Glyphs      Glyphs
+
+	// Sizes and columns.
+	MaskPattern     string
+	Report          bool
+	HumanSizes      bool
+	SIUnits         bool
+	DiskUsage       bool
+	Hash            HashAlgorithm
+	ShowInode       bool
+	ShowPermissions bool
+	ShowOwner       bool
+	ShowGroup       bool
+	ShowMtime       bool
+	TimeFormat      string
+	Color           ColorMode
+	LSColors        string
+}
+
+func joinPath(dir string, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func readDir(fsys fs.FS, path string) ([]os.FileInfo, error) {
+	dirEntries, err := fs.ReadDir(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]os.FileInfo, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		fileInfo, err := dirEntry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, fileInfo)
+	}
+	return names, nil
+}
+
+// ValidatePattern reports whether pattern, a list of filepath.Match
+// patterns separated by |, is well formed.
+func ValidatePattern(pattern string) error {
+	for _, alternative := range strings.Split(pattern, "|") {
+		if _, err := filepath.Match(alternative, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchPattern(pattern string, name string) bool {
+	for _, alternative := range strings.Split(pattern, "|") {
+		if matched, _ := filepath.Match(alternative, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func isHidden(fileInfo os.FileInfo) bool {
+	return strings.HasPrefix(fileInfo.Name(), ".")
+}
+
+func filterEntries(filesInDirInfo []os.FileInfo, opts Options) []os.FileInfo {
+	filtered := filesInDirInfo[:0]
+	for _, fileInfo := range filesInDirInfo {
+		if opts.HideHidden && isHidden(fileInfo) {
+			continue
+		}
+		if opts.HideGitDir && fileInfo.Name() == ".git" {
+			continue
+		}
+		if opts.ExcludePattern != "" && matchPattern(opts.ExcludePattern, fileInfo.Name()) {
+			continue
+		}
+		if opts.IncludePattern != "" && !fileInfo.IsDir() && !matchPattern(opts.IncludePattern, fileInfo.Name()) {
+			continue
+		}
+		if !fileInfo.IsDir() && !matchFileFilters(fileInfo, opts) {
+			continue
+		}
+		filtered = append(filtered, fileInfo)
+	}
+	return filtered
+}
+
+func matchExtension(extensions string, name string) bool {
+	extension := strings.TrimPrefix(path.Ext(name), ".")
+	for _, candidate := range strings.Split(extensions, ",") {
+		if strings.EqualFold(strings.TrimPrefix(candidate, "."), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFileFilters(fileInfo os.FileInfo, opts Options) bool {
+	if opts.Extensions != "" && !matchExtension(opts.Extensions, fileInfo.Name()) {
+		return false
+	}
+	if fileInfo.Size() < int64(opts.MinSize) {
+		return false
+	}
+	return opts.MaxSize == 0 || fileInfo.Size() <= int64(opts.MaxSize)
+}
+
+func lastFileIndexSearch(filesInDirInfo []os.FileInfo, printFiles bool) int {
+	if printFiles {
+		return len(filesInDirInfo) - 1
+	}
+
+	for i := len(filesInDirInfo) - 1; i >= 0; i-- {
+		if filesInDirInfo[i].IsDir() {
+			return i
+		}
+	}
+	return -1
+}
+
+// fileLimitCut returns how many leading entries of a directory are listed
+// under Options.FileLimit and how many listed entries are left out after
+// them. Without printFiles only directories count against the limit.
+func (w *treeWalker) fileLimitCut(filesInDirInfo []os.FileInfo) (cut int, omitted int) {
+	cut = len(filesInDirInfo)
+	if w.opts.FileLimit <= 0 {
+		return
+	}
+
+	listed := 0
+	for i, fileInfo := range filesInDirInfo {
+		if !w.opts.PrintFiles && !fileInfo.IsDir() {
+			continue
+		}
+		listed++
+		if listed == w.opts.FileLimit {
+			cut = i + 1
+		} else if listed > w.opts.FileLimit {
+			omitted++
+		}
+	}
+	if omitted == 0 {
+		cut = len(filesInDirInfo)
+	}
+	return
+}
+
+// ErrTruncated is returned together with the partial tree when a walk runs
+// out of the entry budget set by Options.MaxEntries.
+var ErrTruncated = errors.New("entry budget exhausted")
+
+type treeWalker struct {
+	ctx         context.Context
+	fsys        fs.FS
+	rootPath    string
+	displayRoot string
+	r           Renderer
+	opts        Options
+	stats       Stats
+	ancestors   map[fileID]bool
+	errs        []error
+	pool        *readPool
+	hasher      *hasher
+	readAhead   map[string]dirListing
+	// hidden counts the enclosing directories left out by the file limit,
+	// whose subtrees are only walked for sizes and digests.
+	hidden int
+}
+
+func (w *treeWalker) isVisible(depth int) bool {
+	return w.hidden == 0 && (w.opts.MaxDepth == 0 || depth <= w.opts.MaxDepth)
+}
+
+func (w *treeWalker) spendEntry() error {
+	if w.opts.MaxEntries > 0 && w.stats.Dirs+w.stats.Files >= w.opts.MaxEntries {
+		return fmt.Errorf("tree truncated after %d entries: %w", w.opts.MaxEntries, ErrTruncated)
+	}
+	return nil
+}
+
+func (w *treeWalker) relativePath(path string) string {
+	if w.rootPath == "." {
+		return path
+	}
+	return strings.TrimPrefix(path, w.rootPath+"/")
+}
+
+// rootedError rewrites the paths in errors coming from an os.DirFS back to
+// the form the caller passed in, so that "open a: ..." becomes
+// "open testdata/a: ...".
+func (w *treeWalker) rootedError(err error) error {
+	var pathErr *fs.PathError
+	if w.displayRoot == w.rootPath || !errors.As(err, &pathErr) {
+		return err
+	}
+	return &fs.PathError{
+		Op:   pathErr.Op,
+		Path: filepath.Join(w.displayRoot, filepath.FromSlash(pathErr.Path)),
+		Err:  pathErr.Err,
+	}
+}
+
+func (w *treeWalker) ignoreBase(path string) string {
+	if path == w.rootPath {
+		return ""
+	}
+	return w.relativePath(path)
+}
+
+type dirListing struct {
+	filesInDirInfo []os.FileInfo
+	ignore         *gitIgnore
+	err            error
+}
+
+func (w *treeWalker) readDirEntries(path string, parentIgnore *gitIgnore) (listing dirListing) {
+	if err := w.ctx.Err(); err != nil {
+		listing.err = err
+		return
+	}
+
+	filesInDirInfo, err := readDir(w.fsys, path)
+	if err != nil {
+		listing.err = w.rootedError(err)
+		return
+	}
+
+	listing.ignore = parentIgnore
+	if w.opts.GitIgnore {
+		listing.ignore = parentIgnore.withDir(w.fsys, path, w.ignoreBase(path))
+	}
+
+	resolveSymlinks(w.fsys, path, filesInDirInfo, w.opts.FollowLinks)
+	filesInDirInfo = filterEntries(filesInDirInfo, w.opts)
+	filesInDirInfo = listing.ignore.filter(w.ignoreBase(path), filesInDirInfo)
+	sortEntries(filesInDirInfo, w.opts)
+	listing.filesInDirInfo = filesInDirInfo
+	return
+}
+
+func visitDirRec(w *treeWalker, path string, listing dirListing, depth int) (dirSize int64, digest *Digest, err error) {
+	visible := w.isVisible(depth)
+	if w.opts.Prune && visible {
+		listing.filesInDirInfo = w.pruneEmptyDirs(path, listing, depth)
+	}
+	filesInDirInfo := listing.filesInDirInfo
+
+	digests := w.hashFiles(path, filesInDirInfo)
+	if w.hasher != nil {
+		defer func() {
+			digest = w.hasher.hashDir(filesInDirInfo, digests)
+		}()
+	}
+
+	for _, fileInfo := range filesInDirInfo {
+		if !fileInfo.IsDir() {
+			dirSize += fileInfo.Size()
+		}
+	}
+
+	cut, omitted := len(filesInDirInfo), 0
+	if !w.opts.SkipLargeDirs {
+		cut, omitted = w.fileLimitCut(filesInDirInfo)
+	}
+	lastFileIndex := lastFileIndexSearch(filesInDirInfo[:cut], w.opts.PrintFiles)
+	if lastFileIndex == -1 {
+		return
+	}
+	if omitted > 0 {
+		// The elision line is drawn last, after every listed entry.
+		lastFileIndex = len(filesInDirInfo)
+	}
+
+	if !w.walksHidden() {
+		listing.filesInDirInfo = filesInDirInfo[:cut]
+	}
+	listings := w.prefetchSubdirs(path, listing, depth)
+	for i, fileInfo := range filesInDirInfo {
+		if i == cut && !w.walksHidden() {
+			break
+		}
+		listed := visible && i < cut
+		if listed && (fileInfo.IsDir() || w.opts.PrintFiles) {
+			if err = w.spendEntry(); err != nil {
+				return
+			}
+		}
+
+		childPath := joinPath(path, fileInfo.Name())
+		entry := Entry{
+			Name:       fileInfo.Name(),
+			RelPath:    w.relativePath(childPath),
+			Info:       fileInfo,
+			Depth:      depth,
+			IsLast:     i == lastFileIndex,
+			Size:       fileInfo.Size(),
+			LinkTarget: linkTarget(fileInfo),
+		}
+		entry.Change, entry.OldSize = entryChange(fileInfo)
+		if digests != nil {
+			entry.Digest = digests[i]
+		}
+		if fileInfo.IsDir() {
+			id, hasID := getFileID(fileInfo)
+			entry.Recursive = hasID && w.ancestors[id]
+
+			var subListing dirListing
+			descend := !entry.Recursive && w.shouldDescend(fileInfo, depth)
+			if descend {
+				subListing = w.listDir(childPath, listing.ignore, listings[i])
+				entry.ReadErr = subListing.err
+				if entry.ReadErr != nil && (!w.opts.KeepGoing || w.ctx.Err() != nil) {
+					return dirSize, nil, entry.ReadErr
+				}
+				if entry.ReadErr != nil {
+					w.errs = append(w.errs, entry.ReadErr)
+					descend = false
+				}
+				if entry.ReadErr == nil && w.opts.SkipLargeDirs {
+					if _, omitted := w.fileLimitCut(subListing.filesInDirInfo); omitted > 0 {
+						entry.Omitted = w.opts.FileLimit + omitted
+						descend = w.walksHidden()
+					}
+				}
+			}
+			hideChildren := i >= cut || entry.Omitted > 0
+
+			if listed {
+				w.stats.Dirs++
+				w.r.BeginDir(entry)
+			}
+			if descend {
+				if hasID {
+					w.ancestors[id] = true
+				}
+				if hideChildren {
+					w.hidden++
+				}
+				var subDirSize int64
+				subDirSize, entry.Digest, err = visitDirRec(w, childPath, subListing, depth+1)
+				if digests != nil {
+					digests[i] = entry.Digest
+				}
+				dirSize += subDirSize
+				if w.opts.DiskUsage {
+					entry.Size = subDirSize
+				}
+				if hideChildren {
+					w.hidden--
+				}
+				if hasID {
+					delete(w.ancestors, id)
+				}
+			}
+			if listed {
+				w.r.EndDir(entry)
+			}
+			if err != nil || entry.IsLast {
+				return
+			}
+
+		} else if listed && w.opts.PrintFiles {
+			w.stats.Files++
+			w.stats.Bytes += fileInfo.Size()
+			w.r.File(entry)
+		}
+	}
+	if visible && omitted > 0 {
+		w.r.File(Entry{Name: elisionName, Depth: depth, IsLast: true, Omitted: omitted})
+	}
+	return
+}
+
+func walkTree(ctx context.Context, r Renderer, fsys fs.FS, rootPath string, displayRoot string, opts Options) (stats Stats, err error) {
+	w := &treeWalker{
+		ctx:         ctx,
+		fsys:        fsys,
+		rootPath:    rootPath,
+		displayRoot: displayRoot,
+		r:           r,
+		opts:        opts,
+		ancestors:   make(map[fileID]bool),
+		readAhead:   make(map[string]dirListing),
+	}
+	if opts.DiskUsage || opts.Hash != "" {
+		w.r = &duRenderer{r: r}
+	}
+	if opts.Workers > 1 {
+		w.pool = newReadPool(opts.Workers)
+		defer w.pool.close()
+	}
+	if opts.Hash != "" {
+		w.hasher = newHasher(fsys, opts.Hash, runtime.NumCPU())
+		defer w.hasher.close()
+	}
+
+	rootInfo, err := fs.Stat(fsys, rootPath)
+	if err != nil {
+		return stats, w.rootedError(err)
+	}
+	if id, ok := getFileID(rootInfo); ok {
+		w.ancestors[id] = true
+	}
+
+	listing := w.readDirEntries(rootPath, nil)
+	if listing.err != nil {
+		return stats, listing.err
+	}
+
+	root := Entry{Name: displayRoot, Info: rootInfo, IsLast: true, Size: rootInfo.Size()}
+	w.r.BeginDir(root)
+	rootSize, rootDigest, err := visitDirRec(w, rootPath, listing, 1)
+	if opts.DiskUsage {
+		root.Size = rootSize
+	}
+	root.Digest = rootDigest
+	w.r.EndDir(root)
+	w.r.Summary(w.stats)
+
+	if w.hasher != nil {
+		for _, hashErr := range w.hasher.wait() {
+			w.errs = append(w.errs, w.rootedError(hashErr))
+		}
+	}
+
+	if err == nil && len(w.errs) > 0 {
+		err = errors.Join(w.errs...)
+	}
+	return w.stats, err
+}
+
+func renderTree(ctx context.Context, out io.Writer, fsys fs.FS, rootPath string, displayRoot string, opts Options) (stats Stats, err error) {
+	if opts.Renderer != nil {
+		return walkTree(ctx, opts.Renderer, fsys, rootPath, displayRoot, opts)
+	}
+
+	bufferedOut := bufio.NewWriter(out)
+	stats, err = walkTree(ctx, NewRenderer(bufferedOut, opts), fsys, rootPath, displayRoot, opts)
+	if flushErr := bufferedOut.Flush(); err == nil {
+		err = flushErr
+	}
+	return
+}
+
+// TreeFS writes the tree below root in fsys to out.
+func TreeFS(out io.Writer, fsys fs.FS, root string, opts Options) (stats Stats, err error) {
+	return TreeFSContext(context.Background(), out, fsys, root, opts)
+}
+
+// TreeFSContext is like TreeFS but stops reading directories once ctx is
+// done, returning the tree written so far and the error of ctx.
+func TreeFSContext(ctx context.Context, out io.Writer, fsys fs.FS, root string, opts Options) (stats Stats, err error) {
+	return renderTree(ctx, out, fsys, root, root, opts)
+}
+
+// Tree writes the tree below the directory at path to out and returns the
+// number of directories, files and bytes it listed.
+func Tree(out io.Writer, path string, opts Options) (stats Stats, err error) {
+	return TreeContext(context.Background(), out, path, opts)
+}
+
+// TreeContext is like Tree but stops reading directories once ctx is done,
+// returning the tree written so far and the error of ctx.
+func TreeContext(ctx context.Context, out io.Writer, path string, opts Options) (stats Stats, err error) {
+	return renderTree(ctx, out, os.DirFS(path), ".", path, opts)
+}
+
+// DirTree writes the tree below path to out the way the original dirTree
+// did: hidden entries included, files only if printFiles is set.
+func DirTree(out io.Writer, path string, printFiles bool) error {
+	_, err := Tree(out, path, Options{PrintFiles: printFiles})
+	return err
+}
@@ -0,0 +1,288 @@
+package dirtree
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dirWatcher reports changes to the directories it has been asked to watch.
+// Directory names are the slash-separated paths used with the fs.FS of the
+// watched root.
+type dirWatcher interface {
+	add(name string) error
+	remove(name string)
+	// changes blocks until something happens and returns the directories
+	// whose listing changed and the subdirectories that disappeared.
+	changes() (changed []string, removed []string, err error)
+	close() error
+}
+
+// watchFS serves the listing of a directory from memory once it has been
+// read and starts watching it at the same time, so that rendering the tree
+// again after a change only reads the directories that were reported.
+type watchFS struct {
+	fsys    fs.FS
+	watcher dirWatcher
+	mu      sync.Mutex
+	dirs    map[string][]os.FileInfo
+}
+
+func newWatchFS(fsys fs.FS, watcher dirWatcher) *watchFS {
+	return &watchFS{fsys: fsys, watcher: watcher, dirs: make(map[string][]os.FileInfo)}
+}
+
+func (fsys *watchFS) Open(name string) (fs.File, error) {
+	return fsys.fsys.Open(name)
+}
+
+func (fsys *watchFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.fsys, name)
+}
+
+func (fsys *watchFS) Lstat(name string) (fs.FileInfo, error) {
+	return fs.Lstat(fsys.fsys, name)
+}
+
+func (fsys *watchFS) ReadLink(name string) (string, error) {
+	return fs.ReadLink(fsys.fsys, name)
+}
+
+func (fsys *watchFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	filesInDirInfo, err := fsys.list(name)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries := make([]fs.DirEntry, len(filesInDirInfo))
+	for i, fileInfo := range filesInDirInfo {
+		dirEntries[i] = fs.FileInfoToDirEntry(fileInfo)
+	}
+	return dirEntries, nil
+}
+
+func (fsys *watchFS) list(name string) ([]os.FileInfo, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	if filesInDirInfo, ok := fsys.dirs[name]; ok {
+		return filesInDirInfo, nil
+	}
+	// The watch goes first so that nothing created while the directory is
+	// being read can be missed.
+	if err := fsys.watcher.add(name); err != nil {
+		return nil, err
+	}
+	filesInDirInfo, err := readDir(fsys.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	fsys.dirs[name] = filesInDirInfo
+	return filesInDirInfo, nil
+}
+
+// forget drops the cached listing of name so that it is read again.
+func (fsys *watchFS) forget(name string) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	delete(fsys.dirs, name)
+}
+
+// drop forgets a directory that no longer exists, together with everything
+// that was read below it.
+func (fsys *watchFS) drop(name string) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	for dir := range fsys.dirs {
+		if dir == name || strings.HasPrefix(dir, name+"/") {
+			delete(fsys.dirs, dir)
+		}
+	}
+	fsys.watcher.remove(name)
+}
+
+// listDepth returns the depth the entries of the directory name have in
+// the rendered tree, the children of the root being at depth 1.
+func listDepth(name string) int {
+	if name == "." {
+		return 1
+	}
+	return strings.Count(name, "/") + 2
+}
+
+// ignoreChain returns the .gitignore rules in force for the entries of the
+// directory name, read from the root down as the walk does.
+func (fsys *watchFS) ignoreChain(name string, opts Options) *gitIgnore {
+	if !opts.GitIgnore {
+		return nil
+	}
+	var ignore *gitIgnore
+	ignore = ignore.withDir(fsys, ".", "")
+	if name == "." {
+		return ignore
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		dir := strings.Join(parts[:i+1], "/")
+		ignore = ignore.withDir(fsys, dir, dir)
+	}
+	return ignore
+}
+
+// filteredList lists name with the filters of the walk applied, or nothing
+// if its entries lie deeper than opts.MaxDepth.
+func (fsys *watchFS) filteredList(name string, ignore *gitIgnore, opts Options) []os.FileInfo {
+	if opts.MaxDepth > 0 && listDepth(name) > opts.MaxDepth {
+		return nil
+	}
+	filesInDirInfo, err := fsys.list(name)
+	if err != nil {
+		return nil
+	}
+	return fsys.filter(name, filesInDirInfo, ignore, opts)
+}
+
+func (fsys *watchFS) filter(name string, filesInDirInfo []os.FileInfo, ignore *gitIgnore, opts Options) []os.FileInfo {
+	filesInDirInfo = filterEntries(append([]os.FileInfo(nil), filesInDirInfo...), opts)
+	base := name
+	if name == "." {
+		base = ""
+	}
+	return ignore.filter(base, filesInDirInfo)
+}
+
+// update reads dir again and reports the entries that appeared or vanished
+// since it was last read. New directories are read, and reported, as a whole.
+func (fsys *watchFS) update(dir string, opts Options, report func(added bool, name string)) {
+	fsys.mu.Lock()
+	previous := fsys.dirs[dir]
+	delete(fsys.dirs, dir)
+	fsys.mu.Unlock()
+
+	ignore := fsys.ignoreChain(dir, opts)
+	old := fsys.filter(dir, previous, ignore, opts)
+	current := fsys.filteredList(dir, ignore, opts)
+
+	oldNames := make(map[string]bool, len(old))
+	for _, fileInfo := range old {
+		oldNames[fileInfo.Name()] = true
+	}
+	currentNames := make(map[string]bool, len(current))
+	for _, fileInfo := range current {
+		currentNames[fileInfo.Name()] = true
+		if !oldNames[fileInfo.Name()] {
+			fsys.reportTree(joinPath(dir, fileInfo.Name()), fileInfo, ignore, opts, report)
+		}
+	}
+	for _, fileInfo := range old {
+		if !currentNames[fileInfo.Name()] {
+			report(false, joinPath(dir, fileInfo.Name()))
+			if fileInfo.IsDir() {
+				fsys.drop(joinPath(dir, fileInfo.Name()))
+			}
+		}
+	}
+}
+
+func (fsys *watchFS) reportTree(name string, fileInfo os.FileInfo, parentIgnore *gitIgnore, opts Options, report func(added bool, name string)) {
+	report(true, name)
+	if !fileInfo.IsDir() {
+		return
+	}
+	ignore := parentIgnore
+	if opts.GitIgnore {
+		ignore = parentIgnore.withDir(fsys, name, name)
+	}
+	for _, childInfo := range fsys.filteredList(name, ignore, opts) {
+		fsys.reportTree(joinPath(name, childInfo.Name()), childInfo, ignore, opts, report)
+	}
+}
+
+const clearScreen = "\x1b[H\x1b[2J"
+
+// Watch renders the tree at path and then keeps it up to date until stop
+// is closed: either the whole tree is rendered again after every change, or,
+// with opts.WatchEvents, only the added and removed paths are printed.
+// Errors of the renderings after the first one do not stop the watch; they
+// are passed to onError, if set, instead of being written to out.
+func Watch(out io.Writer, path string, opts Options, stop <-chan struct{}, onError func(error)) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	watcher, err := newDirWatcher(path)
+	if err != nil {
+		return err
+	}
+	defer watcher.close()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-stop:
+			watcher.close()
+		case <-stopped:
+		}
+	}()
+
+	fsys := newWatchFS(os.DirFS(path), watcher)
+	render := func() error {
+		_, err := renderTree(context.Background(), out, fsys, ".", path, opts)
+		return err
+	}
+	if err := render(); err != nil {
+		return err
+	}
+
+	bufferedOut := bufio.NewWriter(out)
+	for {
+		changed, removed, err := watcher.changes()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		if opts.WatchEvents {
+			for _, dir := range changed {
+				fsys.update(dir, opts, func(added bool, name string) {
+					mark := "-"
+					if added {
+						mark = "+"
+					}
+					fmt.Fprintln(bufferedOut, mark, filepath.Join(path, filepath.FromSlash(name)))
+				})
+			}
+			if err := bufferedOut.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, dir := range removed {
+			fsys.drop(dir)
+		}
+		for _, dir := range changed {
+			fsys.forget(dir)
+		}
+		if isTerminal(out) {
+			io.WriteString(out, clearScreen)
+		} else {
+			io.WriteString(out, "\n")
+		}
+		// A directory can vanish between the event and the new rendering;
+		// its parent is reported as well, so the next rendering recovers.
+		if err := render(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+package dirtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB | syscall.IN_ONLYDIR
+
+var errRootRemoved = errors.New("watched directory was removed")
+
+// inotifyWatcher watches directories with a single inotify instance. Its
+// descriptor is non-blocking, so reads wait in the runtime poller and closing
+// the file interrupts a pending changes call.
+type inotifyWatcher struct {
+	root  string
+	file  *os.File
+	dirs  map[int32]string
+	wds   map[string]int32
+	event []byte
+}
+
+func newDirWatcher(root string) (dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	return &inotifyWatcher{
+		root:  root,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		dirs:  make(map[int32]string),
+		wds:   make(map[string]int32),
+		event: make([]byte, 64*1024),
+	}, nil
+}
+
+func (w *inotifyWatcher) control(f func(fd int) error) error {
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := conn.Control(func(fd uintptr) { opErr = f(int(fd)) }); err != nil {
+		return err
+	}
+	return opErr
+}
+
+func (w *inotifyWatcher) add(name string) error {
+	dirPath := filepath.Join(w.root, filepath.FromSlash(name))
+	return w.control(func(fd int) error {
+		wd, err := syscall.InotifyAddWatch(fd, dirPath, inotifyMask)
+		if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: dirPath, Err: err}
+		}
+		w.dirs[int32(wd)] = name
+		w.wds[name] = int32(wd)
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) remove(name string) {
+	w.control(func(fd int) error {
+		for dir, wd := range w.wds {
+			if dir == name || strings.HasPrefix(dir, name+"/") {
+				syscall.InotifyRmWatch(fd, uint32(wd))
+				delete(w.wds, dir)
+				delete(w.dirs, wd)
+			}
+		}
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) changes() (changed []string, removed []string, err error) {
+	n, err := w.file.Read(w.event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	markChanged := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			changed = append(changed, dir)
+		}
+	}
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+		wd := int32(binary.NativeEndian.Uint32(w.event[offset:]))
+		mask := binary.NativeEndian.Uint32(w.event[offset+4:])
+		nameLen := int(binary.NativeEndian.Uint32(w.event[offset+12:]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		name := strings.TrimRight(string(w.event[nameStart:nameStart+nameLen]), "\x00")
+		offset = nameStart + nameLen
+
+		switch dir, watched := w.dirs[wd]; {
+		case mask&syscall.IN_Q_OVERFLOW != 0:
+			for dir := range w.wds {
+				markChanged(dir)
+			}
+		case !watched:
+		case mask&syscall.IN_IGNORED != 0:
+			if dir == "." {
+				return nil, nil, errRootRemoved
+			}
+			delete(w.dirs, wd)
+			if w.wds[dir] == wd {
+				delete(w.wds, dir)
+			}
+		default:
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0 {
+				removed = append(removed, joinPath(dir, name))
+			}
+			markChanged(dir)
+		}
+	}
+	return changed, removed, nil
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package dirtree
+
+import "errors"
+
+func newDirWatcher(root string) (dirWatcher, error) {
+	return nil, errors.New("watch mode needs inotify and is only supported on Linux")
+}
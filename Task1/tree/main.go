@@ -1,125 +1,219 @@
 package main
 
 import (
-	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+
+	"github.com/barzug/TParkGoTasks/Task1/tree/dirtree"
 )
 
 const (
-	filePrintFormat     = "├───%s (%s)\n"
-	lastFilePrintFormat = "└───%s (%s)\n"
-	dirPrintFormat      = "├───%s\n"
-	lastDirPrintFormat  = "└───%s\n"
-	defaultIndention    = "\t"
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
 )
 
-func readDir(path string) ([]os.FileInfo, error) {
-	dirOrFile, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer dirOrFile.Close()
+// cliOptions holds the tree options together with the flags that select
+// what the command does instead of how the tree is printed.
+type cliOptions struct {
+	tree       dirtree.Options
+	showHidden bool
+	diffBase   string
+	watch      bool
+	buildFrom  string
+}
 
-	names, err := dirOrFile.Readdir(-1)
-	if err != nil {
-		return nil, err
-	}
-	return names, nil
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	return dirtree.DirTree(out, path, printFiles)
 }
 
-func getSizeString(fileInfo os.FileInfo) string {
-	if fileInfo.Name() == "main.go" {
-		return "vary"
-	}
+func newFlagSet(cli *cliOptions, stderr io.Writer) *flag.FlagSet {
+	opts := &cli.tree
 
-	size := fileInfo.Size()
-	if size == 0 {
-		return "empty"
+	flagSet := flag.NewFlagSet("tree", flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	flagSet.Usage = func() {
+		fmt.Fprintln(stderr, "usage: tree [flags] [path | archive.zip | archive.tar | archive.tar.gz]")
+		fmt.Fprintln(stderr, "       tree -diff old [flags] new")
+		flagSet.PrintDefaults()
 	}
 
-	return fmt.Sprint(size) + "b"
+	flagSet.BoolVar(&opts.PrintFiles, "f", false, "print files as well as directories")
+	flagSet.BoolVar(&cli.showHidden, "a", false, "show hidden files and directories (names starting with a dot)")
+	flagSet.IntVar(&opts.MaxDepth, "L", 0, "descend at most `depth` levels (0 means no limit)")
+	flagSet.StringVar(&opts.ExcludePattern, "I", "", "do not list entries matching `pattern` (alternatives separated by |)")
+	flagSet.StringVar(&opts.IncludePattern, "P", "", "list only files matching `pattern` (alternatives separated by |)")
+	flagSet.StringVar(&opts.Extensions, "ext", "", "list only files with one of the `extensions` (separated by commas)")
+	flagSet.Var(&opts.MinSize, "minsize", "list only files of at least `size` bytes (suffixes K, M, G... allowed)")
+	flagSet.Var(&opts.MaxSize, "maxsize", "list only files of at most `size` bytes (0 means no limit)")
+	flagSet.IntVar(&opts.FileLimit, "filelimit", 0, "list at most `n` entries of each directory and count the rest on a final line (0 means no limit)")
+	flagSet.BoolVar(&opts.SkipLargeDirs, "skiplarge", false, "with -filelimit, do not open directories over the limit instead of cutting their listing short")
+	flagSet.BoolVar(&opts.Prune, "prune", false, "omit directories with no file passing the filters below them (files count even without -f)")
+	flagSet.StringVar(&opts.MaskPattern, "mask", "", "print \"vary\" instead of the size of entries matching `pattern` (alternatives separated by |, matched against the path relative to the root if the alternative contains a /)")
+
+	opts.Sort = dirtree.SortByName
+	flagSet.Var(&opts.Sort, "sort", "sort entries by `mode`: name, version, size (largest first), mtime (newest first) or none")
+	flagSet.BoolVar(&opts.DirsFirst, "dirsfirst", false, "list directories before files")
+	flagSet.BoolVar(&opts.ReverseSort, "r", false, "reverse the sort order")
+	flagSet.BoolVar(&opts.FollowLinks, "l", false, "follow symbolic links to directories, skipping links that loop back to an ancestor")
+	flagSet.BoolVar(&opts.KeepGoing, "k", false, "keep walking past directories that cannot be read and report them all at the end")
+	flagSet.IntVar(&opts.MaxEntries, "maxentries", 0, "stop after listing `n` entries and report the tree as truncated (0 means no limit)")
+	flagSet.IntVar(&opts.Workers, "j", 1, "read up to `n` directories in parallel")
+	flagSet.BoolVar(&opts.GitIgnore, "gitignore", false, "hide entries ignored by .gitignore files found during the walk")
+	flagSet.BoolVar(&opts.HideGitDir, "nogit", false, "hide the .git directory even when hidden files are shown")
+	flagSet.StringVar(&cli.diffBase, "diff", "", "compare the tree against the `old` directory or archive, marking entries that were added, removed or changed size")
+
+	flagSet.BoolVar(&opts.ShowInode, "inodes", false, "print the inode number of each entry")
+	flagSet.BoolVar(&opts.ShowPermissions, "p", false, "print the permissions of each entry")
+	flagSet.BoolVar(&opts.ShowOwner, "u", false, "print the owner of each entry")
+	flagSet.BoolVar(&opts.ShowGroup, "g", false, "print the group of each entry")
+	flagSet.BoolVar(&opts.ShowMtime, "D", false, "print the modification time of each entry")
+	flagSet.StringVar(&opts.TimeFormat, "timefmt", dirtree.DefaultTimeFormat, "Go time `layout` used by -D")
+
+	opts.Format = dirtree.FormatText
+	flagSet.Var(&opts.Format, "format", "output `format`: text, ascii, unicode, indent, html, markdown, json or xml")
+	flagSet.Var(&opts.IndentStyle, "indent", "indent the text formats with `style`: tabs or spaces (default tabs for text and indent, spaces for ascii and unicode)")
+	flagSet.IntVar(&opts.IndentWidth, "indentwidth", 0, "indent each level by `n` tabs or spaces (0 means one tab or four spaces; at least two spaces when connectors are drawn)")
+	flagSet.Var(&opts.Glyphs, "glyphs", "draw the text formats with `set`: classic, ascii, or four characters for the tee, corner, horizontal and vertical lines")
+	opts.Color = dirtree.ColorAuto
+	flagSet.Var(&opts.Color, "color", "color entry names using LS_COLORS: `when` is auto (only on a terminal without NO_COLOR), always or never")
+	flagSet.BoolVar(&cli.watch, "watch", false, "keep running and render the tree again whenever something changes under the root")
+	flagSet.BoolVar(&opts.WatchEvents, "events", false, "with -watch, print added (+) and removed (-) paths instead of the whole tree")
+	flagSet.StringVar(&cli.buildFrom, "from", "", "instead of printing, create under path the directories and zero-filled files drawn in the tree text in `file` (- reads standard input)")
+	flagSet.BoolVar(&opts.Report, "report", false, "print the number of directories, files and bytes after the tree")
+	flagSet.BoolVar(&opts.HumanSizes, "h", false, "print sizes in a human readable way using powers of 1024 (68.7K, 1.2M)")
+	flagSet.BoolVar(&opts.SIUnits, "si", false, "like -h, but use powers of 1000 (70.4k, 1.3M)")
+	flagSet.Var(&opts.Hash, "hash", "print a checksum of every file and a digest of every directory and of the root computed with `algorithm`: sha256, crc32 or md5")
+	flagSet.BoolVar(&opts.DiskUsage, "du", false, "print the cumulative size of every directory")
+	return flagSet
 }
 
-func lastFileIndexSearch(filesInDirInfo []os.FileInfo, printFiles bool) int {
-	if printFiles {
-		return len(filesInDirInfo) - 1
-	}
+func parseArgs(args []string, stderr io.Writer) (path string, cli cliOptions, err error) {
+	flagSet := newFlagSet(&cli, stderr)
+	opts := &cli.tree
 
-	for i := len(filesInDirInfo) - 1; i >= 0; i-- {
-		if filesInDirInfo[i].IsDir() {
-			return i
+	var positional []string
+	for {
+		if err = flagSet.Parse(args); err != nil {
+			return
+		}
+		if flagSet.NArg() == 0 {
+			break
 		}
+		positional = append(positional, flagSet.Arg(0))
+		args = flagSet.Args()[1:]
 	}
-	return -1
-}
 
-func printFile(out *bytes.Buffer, indention string, fileInfo os.FileInfo, isLast bool) {
-	size := getSizeString(fileInfo)
+	opts.HideHidden = !cli.showHidden
 
-	if isLast {
-		fmt.Fprintf(out, indention+lastFilePrintFormat, fileInfo.Name(), size)
-		return
+	switch len(positional) {
+	case 0:
+		path = "."
+	case 1:
+		path = positional[0]
+	default:
+		err = fmt.Errorf("expected at most one path, got %d", len(positional))
 	}
-
-	fmt.Fprintf(out, indention+filePrintFormat, fileInfo.Name(), size)
-}
-
-func printDir(out *bytes.Buffer, indention string, fileInfo os.FileInfo, isLast bool) {
-	if isLast {
-		fmt.Fprintf(out, indention+lastDirPrintFormat, fileInfo.Name())
-		return
+	if err == nil && opts.MaxDepth < 0 {
+		err = fmt.Errorf("invalid depth %d: must not be negative", opts.MaxDepth)
 	}
-
-	fmt.Fprintf(out, indention+dirPrintFormat, fileInfo.Name())
+	if err == nil && opts.IndentWidth < 0 {
+		err = fmt.Errorf("invalid indentation width %d: must not be negative", opts.IndentWidth)
+	}
+	if err == nil && opts.FileLimit < 0 {
+		err = fmt.Errorf("invalid file limit %d: must not be negative", opts.FileLimit)
+	}
+	if err == nil && opts.SkipLargeDirs && opts.FileLimit == 0 {
+		err = errors.New("-skiplarge needs -filelimit")
+	}
+	if err == nil && opts.MaxEntries < 0 {
+		err = fmt.Errorf("invalid entry budget %d: must not be negative", opts.MaxEntries)
+	}
+	if err == nil && opts.Workers < 1 {
+		err = fmt.Errorf("invalid number of workers %d: must be at least 1", opts.Workers)
+	}
+	if err == nil && opts.ExcludePattern != "" {
+		err = dirtree.ValidatePattern(opts.ExcludePattern)
+	}
+	if err == nil && opts.IncludePattern != "" {
+		err = dirtree.ValidatePattern(opts.IncludePattern)
+	}
+	if err == nil && opts.MaskPattern != "" {
+		err = dirtree.ValidatePattern(opts.MaskPattern)
+	}
+	if err == nil && opts.WatchEvents && !cli.watch {
+		err = errors.New("-events needs -watch")
+	}
+	if err == nil && cli.watch && cli.diffBase != "" {
+		err = errors.New("-watch cannot be combined with -diff")
+	}
+	if err == nil && cli.buildFrom != "" && (cli.watch || cli.diffBase != "") {
+		err = errors.New("-from cannot be combined with -watch or -diff")
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "tree:", err)
+		flagSet.Usage()
+	}
+	return
 }
 
-func visitDirRec(out *bytes.Buffer, path string, printFiles bool, indention string) (err error) {
-	filesInDirInfo, err := readDir(path)
+func run(args []string, stdout, stderr io.Writer) int {
+	path, cli, err := parseArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
 	if err != nil {
-		return err
+		return exitUsage
 	}
 
-	lastFileIndex := lastFileIndexSearch(filesInDirInfo, printFiles)
-	if lastFileIndex == -1 {
-		return
+	opts := cli.tree
+	opts.Color = dirtree.ResolveColorMode(opts.Color, stdout)
+	opts.LSColors = os.Getenv("LS_COLORS")
+
+	switch {
+	case cli.buildFrom != "":
+		err = buildTreeFrom(path, cli.buildFrom)
+	case cli.watch:
+		err = dirtree.Watch(stdout, path, opts, nil, func(err error) {
+			fmt.Fprintln(stderr, "tree:", err)
+		})
+	case cli.diffBase != "":
+		_, err = dirtree.Diff(stdout, cli.diffBase, path, opts)
+	case dirtree.IsArchive(path):
+		_, err = dirtree.Archive(stdout, path, opts)
+	default:
+		_, err = dirtree.Tree(stdout, path, opts)
 	}
-
-	for i, fileInfo := range filesInDirInfo {
-		if fileInfo.IsDir() {
-			printDir(out, indention, fileInfo, i == lastFileIndex)
-
-			if i == lastFileIndex {
-				return visitDirRec(out, path+"/"+fileInfo.Name(), printFiles, indention+defaultIndention)
-			}
-
-			err = visitDirRec(out, path+"/"+fileInfo.Name(), printFiles, indention+"│"+defaultIndention)
-			if err != nil {
-				return
-			}
-
-		} else if printFiles {
-			printFile(out, indention, fileInfo, i == lastFileIndex)
-		}
+	if err != nil {
+		fmt.Fprintln(stderr, "tree:", err)
+		return exitError
 	}
-	return
-}
-
-func dirTree(out *bytes.Buffer, path string, printFiles bool) (err error) {
-	return visitDirRec(out, path, printFiles, "")
+	return exitOK
 }
 
-func main() {
-	out := new(bytes.Buffer)
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+// buildTreeFrom creates under root the tree described in specPath, or on
+// standard input if specPath is "-".
+func buildTreeFrom(root string, specPath string) error {
+	in := os.Stdin
+	if specPath != "-" {
+		specFile, err := os.Open(specPath)
+		if err != nil {
+			return err
+		}
+		defer specFile.Close()
+		in = specFile
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
+
+	entries, err := dirtree.ParseText(in)
 	if err != nil {
-		panic(err.Error())
+		return fmt.Errorf("%s: %w", specPath, err)
 	}
+	return dirtree.Build(root, entries)
+}
 
-	print(out.String())
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
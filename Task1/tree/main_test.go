@@ -2,32 +2,34 @@ package main
 
 import (
 	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/barzug/TParkGoTasks/Task1/tree/dirtree"
 )
 
-const testFullResult = `├───main.go (vary)
-├───main_test.go (1318b)
-└───testdata
-	├───project
-	│	├───file.txt (19b)
-	│	└───gopher.png (70372b)
-	├───static
-	│	├───css
-	│	│	└───body.css (28b)
-	│	├───html
-	│	│	└───index.html (57b)
-	│	└───js
-	│		└───site.js (10b)
-	├───zline
-	│	└───empty.txt (empty)
-	└───zzfile.txt (empty)
+const testFullResult = `├───project
+│	├───file.txt (19b)
+│	└───gopher.png (70372b)
+├───static
+│	├───css
+│	│	└───body.css (28b)
+│	├───html
+│	│	└───index.html (57b)
+│	└───js
+│		└───site.js (10b)
+├───zline
+│	└───empty.txt (empty)
+└───zzfile.txt (empty)
 `
 
 func TestTreeFull(t *testing.T) {
 	out := new(bytes.Buffer)
-	err := dirTree(out, ".", true)
-	if err != nil {
-		t.Errorf("test for OK Failed - error")
+	if code := run([]string{"-a", "-f", "testdata"}, out, io.Discard); code != exitOK {
+		t.Errorf("test for OK Failed - exit code %d", code)
 	}
 	result := out.String()
 	if result != testFullResult {
@@ -35,18 +37,17 @@ func TestTreeFull(t *testing.T) {
 	}
 }
 
-const testDirResult = `└───testdata
-	├───project
-	├───static
-	│	├───css
-	│	├───html
-	│	└───js
-	└───zline
+const testDirResult = `├───project
+├───static
+│	├───css
+│	├───html
+│	└───js
+└───zline
 `
 
 func TestTreeDir(t *testing.T) {
 	out := new(bytes.Buffer)
-	err := dirTree(out, ".", false)
+	err := dirTree(out, "testdata", false)
 	if err != nil {
 		t.Errorf("test for OK Failed - error")
 	}
@@ -55,3 +56,114 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+func TestParseArgs(t *testing.T) {
+	path, cli, err := parseArgs([]string{"-a", "testdata", "-f", "-L", "2", "-I", "*.png", "-sort", "size", "-r", "-j", "4"}, io.Discard)
+	if err != nil {
+		t.Fatalf("test for OK Failed - error: %v", err)
+	}
+	expected := cliOptions{showHidden: true, tree: dirtree.Options{PrintFiles: true, MaxDepth: 2, ExcludePattern: "*.png", Sort: dirtree.SortBySize, ReverseSort: true, Format: dirtree.FormatText, Workers: 4, TimeFormat: dirtree.DefaultTimeFormat, Color: dirtree.ColorAuto}}
+	if path != "testdata" || cli != expected {
+		t.Errorf("test for OK Failed - got path %q options %+v", path, cli)
+	}
+
+	badArgs := [][]string{
+		{"-x"},
+		{"-L", "-1"},
+		{"-P", "[a"},
+		{"one", "two"},
+		{"-sort", "random"},
+		{"-format", "yaml"},
+		{"-report=maybe"},
+		{"-j", "0"},
+		{"-color", "sometimes"},
+		{"-hash", "sha1"},
+		{"-minsize", "big"},
+		{"-events"},
+		{"-watch", "-diff", "old", "new"},
+		{"-from", "layout.txt", "-watch"},
+		{"-indent", "mixed"},
+		{"-filelimit", "-1"},
+		{"-skiplarge"},
+		{"-maxentries", "-5"},
+		{"-indentwidth", "-2"},
+		{"-glyphs", "fancy"},
+	}
+	for _, args := range badArgs {
+		if _, _, err := parseArgs(args, io.Discard); err == nil {
+			t.Errorf("test for %v Failed - expected error", args)
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	if code := run([]string{"-f", "testdata/zline"}, io.Discard, io.Discard); code != exitOK {
+		t.Errorf("test for OK Failed - exit code %d", code)
+	}
+	if code := run([]string{"-L"}, io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("test for usage Failed - exit code %d", code)
+	}
+	if code := run([]string{"testdata/missing"}, io.Discard, io.Discard); code != exitError {
+		t.Errorf("test for missing path Failed - exit code %d", code)
+	}
+}
+
+func TestRunHashRoot(t *testing.T) {
+	var digests []string
+	for _, dir := range []string{t.TempDir(), t.TempDir()} {
+		os.MkdirAll(filepath.Join(dir, "src"), 0755)
+		os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+		os.WriteFile(filepath.Join(dir, "README"), []byte("tree"), 0644)
+
+		out := new(bytes.Buffer)
+		if code := run([]string{"-f", "-hash", "sha256", dir}, out, io.Discard); code != exitOK {
+			t.Fatalf("test for OK Failed - exit code %d", code)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		footer := lines[len(lines)-1]
+		if !strings.HasSuffix(footer, "  "+dir) {
+			t.Fatalf("test for root digest Failed - got:\n%v", out.String())
+		}
+		digests = append(digests, strings.TrimSuffix(footer, "  "+dir))
+	}
+	if digests[0] != digests[1] || len(digests[0]) != 64 {
+		t.Errorf("test for root digest Failed - got %q and %q for identical trees", digests[0], digests[1])
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "old"), 0755)
+	os.MkdirAll(filepath.Join(root, "new"), 0755)
+	os.WriteFile(filepath.Join(root, "new", "added.txt"), []byte("hello"), 0644)
+
+	stdout := new(bytes.Buffer)
+	if code := run([]string{"-diff", filepath.Join(root, "old"), "-f", filepath.Join(root, "new")}, stdout, io.Discard); code != exitOK {
+		t.Errorf("test for OK Failed - exit code %d", code)
+	}
+	if expected := "└───added.txt [added] (5b)\n"; stdout.String() != expected {
+		t.Errorf("test for OK Failed - got %q, expected %q", stdout.String(), expected)
+	}
+	if code := run([]string{"-diff", filepath.Join(root, "missing"), root}, io.Discard, io.Discard); code != exitError {
+		t.Errorf("test for missing base Failed - exit code %d", code)
+	}
+}
+
+func TestRunBuild(t *testing.T) {
+	root := t.TempDir()
+	layout := filepath.Join(root, "layout.txt")
+	os.WriteFile(layout, []byte("├───in\n│\t└───batch.csv (12b)\n└───out\n"), 0644)
+
+	target := filepath.Join(root, "drop")
+	if code := run([]string{"-from", layout, target}, io.Discard, io.Discard); code != exitOK {
+		t.Fatalf("test for OK Failed - exit code %d", code)
+	}
+	out := new(bytes.Buffer)
+	dirTree(out, target, true)
+	if expected := "├───in\n│\t└───batch.csv (12b)\n└───out\n"; out.String() != expected {
+		t.Errorf("test for OK Failed - got %q, expected %q", out.String(), expected)
+	}
+	if code := run([]string{"-from", layout, target}, io.Discard, io.Discard); code != exitError {
+		t.Errorf("test for existing tree Failed - exit code %d", code)
+	}
+}